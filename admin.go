@@ -0,0 +1,268 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdminHandler implements an http.Handler that allows the listeners
+// of a Registry to be inspected and changed while the program runs.
+//
+// A GET request returns a JSON array describing each listener.  A PUT
+// or POST request with a JSON body changes the prefix and/or priority
+// range of a listener, e.g.:
+//
+//	{"id": 3, "prefix": "github.com/acme/db", "min": "debug", "ttl": "10m"}
+//
+// All fields other than id are optional.  A listener accepting a
+// contiguous range of priorities is given the range min to max, one
+// accepting a set built with Only may only be narrowed by min and
+// max.  Instead of min and max, priorities may list the exact levels
+// to accept, e.g., "priorities": ["info", "error"].  A change that
+// would leave a listener with no priorities is rejected.  When ttl is set the change
+// is temporary, and the previous prefix and priorities are restored
+// once the ttl has passed.  The updated listener is returned as JSON.
+type AdminHandler struct {
+	registry *Registry
+	// mu guards overrides
+	mu        sync.Mutex
+	overrides map[uint64]*adminOverride
+}
+
+// adminOverride records the settings to restore when a temporary
+// change expires.
+type adminOverride struct {
+	pattern    Pattern
+	priorities Priorities
+	expires    time.Time
+	timer      *time.Timer
+}
+
+// adminListener is the JSON representation of a ListenerInfo.
+type adminListener struct {
+	Id         uint64     `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Pattern    string     `json:"pattern"`
+	Min        string     `json:"min"`
+	Max        string     `json:"max"`
+	Priorities []string   `json:"priorities"`
+	Sink       string     `json:"sink"`
+	Expires    *time.Time `json:"expires,omitempty"`
+}
+
+// adminUpdate is the JSON request body accepted by PUT and POST.
+type adminUpdate struct {
+	Id     uint64  `json:"id"`
+	Prefix *string `json:"prefix"`
+	Min    *string `json:"min"`
+	Max    *string `json:"max"`
+	// Priorities, when set, replaces the set of priorities accepted
+	// by the listener, it cannot be combined with Min or Max
+	Priorities []string `json:"priorities"`
+	TTL        string   `json:"ttl"`
+}
+
+// NewAdminHandler returns an AdminHandler for r, or for
+// DefaultRegistry if r is nil.
+func NewAdminHandler(r *Registry) *AdminHandler {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return &AdminHandler{
+		registry:  r,
+		overrides: make(map[uint64]*adminOverride),
+	}
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		h.list(w)
+	case http.MethodPut, http.MethodPost:
+		h.update(w, req)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list writes a JSON array describing each listener.
+func (h *AdminHandler) list(w http.ResponseWriter) {
+	infos := h.registry.Listeners()
+	listeners := make([]adminListener, len(infos))
+	for i, info := range infos {
+		listeners[i] = h.describe(info)
+	}
+	writeJSON(w, http.StatusOK, listeners)
+}
+
+// update applies an adminUpdate to a listener.
+func (h *AdminHandler) update(w http.ResponseWriter, req *http.Request) {
+	var u adminUpdate
+	if err := json.NewDecoder(req.Body).Decode(&u); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if u.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(u.TTL); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("invalid ttl %q", u.TTL), http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pattern, priorities, ok := h.registry.pattern(u.Id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no listener with id %d", u.Id), http.StatusNotFound)
+		return
+	}
+
+	nextPattern, nextPriorities := pattern, priorities
+	if u.Prefix != nil {
+		nextPattern = Prefix(*u.Prefix)
+	}
+	if u.Priorities != nil {
+		if u.Min != nil || u.Max != nil {
+			http.Error(w, "priorities cannot be combined with min or max", http.StatusBadRequest)
+			return
+		}
+		nextPriorities = 0
+		for _, name := range u.Priorities {
+			p, err := ParsePriority(name)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid priority %q: %v", name, err), http.StatusBadRequest)
+				return
+			}
+			nextPriorities |= Only(p)
+		}
+		if nextPriorities == 0 {
+			http.Error(w, fmt.Sprintf("priorities leave listener %d with no priorities", u.Id), http.StatusBadRequest)
+			return
+		}
+	} else if u.Min != nil || u.Max != nil {
+		min, max := priorities.Min(), priorities.Max()
+		var err error
+		if u.Min != nil {
+			if min, err = ParsePriority(*u.Min); err != nil {
+				http.Error(w, fmt.Sprintf("invalid min %q: %v", *u.Min, err), http.StatusBadRequest)
+				return
+			}
+		}
+		if u.Max != nil {
+			if max, err = ParsePriority(*u.Max); err != nil {
+				http.Error(w, fmt.Sprintf("invalid max %q: %v", *u.Max, err), http.StatusBadRequest)
+				return
+			}
+		}
+		if priorities == Range(priorities.Min(), priorities.Max()) {
+			nextPriorities = Range(min, max)
+		} else if min < priorities.Min() || max > priorities.Max() {
+			http.Error(w, fmt.Sprintf("listener %d accepts %s, use priorities to add levels", u.Id, priorities), http.StatusBadRequest)
+			return
+		} else {
+			// narrow a set built with Only, rather than filling in
+			// the levels it left out
+			nextPriorities = priorities & Range(min, max)
+		}
+		if nextPriorities == 0 {
+			http.Error(w, fmt.Sprintf("min %s and max %s leave listener %d with no priorities", min, max, u.Id), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !h.registry.update(u.Id, nextPattern, nextPriorities) {
+		http.Error(w, fmt.Sprintf("no listener with id %d", u.Id), http.StatusNotFound)
+		return
+	}
+
+	o := h.overrides[u.Id]
+	if ttl > 0 {
+		if o == nil {
+			o = &adminOverride{pattern: pattern, priorities: priorities}
+			h.overrides[u.Id] = o
+		} else {
+			o.timer.Stop()
+		}
+		o.expires = time.Now().Add(ttl)
+		id := u.Id
+		o.timer = time.AfterFunc(ttl, func() { h.revert(id, o) })
+	} else if o != nil {
+		// a permanent change replaces any temporary one
+		o.timer.Stop()
+		delete(h.overrides, u.Id)
+	}
+
+	info, _ := h.registry.Listener(u.Id)
+	writeJSON(w, http.StatusOK, h.describeLocked(info))
+}
+
+// revert restores the settings recorded in o, unless o has since been
+// replaced or cancelled.
+func (h *AdminHandler) revert(id uint64, o *adminOverride) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.overrides[id] != o {
+		return
+	}
+	delete(h.overrides, id)
+	h.registry.update(id, o.pattern, o.priorities)
+}
+
+// Close cancels any temporary changes that have not yet expired,
+// leaving the listeners with their current settings.
+func (h *AdminHandler) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, o := range h.overrides {
+		o.timer.Stop()
+		delete(h.overrides, id)
+	}
+}
+
+// describe returns the JSON representation of info.
+func (h *AdminHandler) describe(info ListenerInfo) adminListener {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.describeLocked(info)
+}
+
+// describeLocked returns the JSON representation of info, the caller
+// must hold h.mu.
+func (h *AdminHandler) describeLocked(info ListenerInfo) adminListener {
+	l := adminListener{
+		Id:         info.Id,
+		Prefix:     info.Prefix,
+		Pattern:    info.Pattern,
+		Min:        info.Min.String(),
+		Max:        info.Max.String(),
+		Priorities: []string{},
+		Sink:       info.Sink,
+	}
+	for p := Trace; p <= None; p++ {
+		if info.Priorities.Has(p) {
+			l.Priorities = append(l.Priorities, p.String())
+		}
+	}
+	if o, ok := h.overrides[info.Id]; ok {
+		expires := o.expires
+		l.Expires = &expires
+	}
+	return l
+}
+
+// writeJSON writes v to w as JSON with the specified status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
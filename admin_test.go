@@ -0,0 +1,194 @@
+package trace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func adminRequest(t *testing.T, h http.Handler, method, body string) (int, string) {
+	req := httptest.NewRequest(method, "/trace", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestAdminHandlerList(t *testing.T) {
+	r := NewRegistry(nil)
+	handle := r.Register("github.com/acme", Info, discardListenerFn)
+	defer handle.Remove()
+
+	h := NewAdminHandler(r)
+	defer h.Close()
+
+	code, body := adminRequest(t, h, http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, body)
+	}
+
+	var listeners []adminListener
+	if err := json.Unmarshal([]byte(body), &listeners); err != nil {
+		t.Fatalf("unable to decode %s: %v", body, err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("expected 1 listener, got %d", len(listeners))
+	}
+
+	l := listeners[0]
+	if l.Id != handle.Id() || l.Prefix != "github.com/acme" || l.Min != "Info" || l.Max != "None" {
+		t.Errorf("unexpected listener: %+v", l)
+	}
+	if !strings.HasSuffix(l.Sink, "discardListenerFn") {
+		t.Errorf("expected sink to name discardListenerFn, got %s", l.Sink)
+	}
+
+	if code, _ := adminRequest(t, h, http.MethodDelete, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for DELETE, got %d", code)
+	}
+}
+
+func TestAdminHandlerUpdate(t *testing.T) {
+	r := NewRegistry(nil)
+	handle := r.Register("github.com/acme", Info, discardListenerFn)
+	defer handle.Remove()
+
+	h := NewAdminHandler(r)
+	defer h.Close()
+
+	body := `{"id": ` + jsonId(handle) + `, "prefix": "github.com/acme/db", "min": "debug", "max": "warn"}`
+	code, resp := adminRequest(t, h, http.MethodPut, body)
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, resp)
+	}
+
+	tr := r.Tracer("github.com/acme/db")
+	if !tr.Enabled(Debug) || tr.Enabled(Error) {
+		t.Errorf("expected Debug through Warn to be enabled for github.com/acme/db")
+	}
+	if r.Tracer("github.com/acme/http").Enabled(Warn) {
+		t.Errorf("expected github.com/acme/http to be disabled")
+	}
+
+	for _, v := range []struct {
+		body string
+		code int
+	}{
+		{`{"id": 9999, "min": "debug"}`, http.StatusNotFound},
+		{`{"id": ` + jsonId(handle) + `, "min": "loud"}`, http.StatusBadRequest},
+		{`{"id": ` + jsonId(handle) + `, "ttl": "soon"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	} {
+		if code, resp := adminRequest(t, h, http.MethodPost, v.body); code != v.code {
+			t.Errorf("%s: expected status %d, got %d: %s", v.body, v.code, code, resp)
+		}
+	}
+}
+
+func TestAdminHandlerUpdatePriorities(t *testing.T) {
+	r := NewRegistry(nil)
+	ranged := r.RegisterPriorities(Prefix("ranged"), Range(Trace, Debug), discardListenerFn)
+	defer ranged.Remove()
+	only := r.RegisterPriorities(Prefix("only"), Only(Info, Error), discardListenerFn)
+	defer only.Remove()
+
+	h := NewAdminHandler(r)
+	defer h.Close()
+
+	// raising min above the current max would leave no priorities
+	if code, resp := adminRequest(t, h, http.MethodPut, `{"id": `+jsonId(ranged)+`, "min": "warn"}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an empty set, got %d: %s", code, resp)
+	}
+	if _, priorities, _ := r.pattern(ranged.Id()); priorities != Range(Trace, Debug) {
+		t.Errorf("expected the listener to be unchanged, got %s", priorities)
+	}
+
+	// a set built with Only is narrowed, not filled in to a range
+	if code, resp := adminRequest(t, h, http.MethodPut, `{"id": `+jsonId(only)+`, "min": "info", "max": "error"}`); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", code, resp)
+	}
+	if _, priorities, _ := r.pattern(only.Id()); priorities != Only(Info, Error) {
+		t.Errorf("expected %s, got %s", Only(Info, Error), priorities)
+	}
+
+	// asking an Only set for more verbosity must not silently succeed
+	if code, resp := adminRequest(t, h, http.MethodPut, `{"id": `+jsonId(only)+`, "min": "trace"}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for widening an Only set, got %d: %s", code, resp)
+	}
+	if _, priorities, _ := r.pattern(only.Id()); priorities != Only(Info, Error) {
+		t.Errorf("expected %s, got %s", Only(Info, Error), priorities)
+	}
+	if code, resp := adminRequest(t, h, http.MethodPut, `{"id": `+jsonId(only)+`, "min": "warn"}`); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", code, resp)
+	}
+	if _, priorities, _ := r.pattern(only.Id()); priorities != Only(Error) {
+		t.Errorf("expected %s, got %s", Only(Error), priorities)
+	}
+
+	// an explicit set replaces the priorities
+	if code, resp := adminRequest(t, h, http.MethodPut, `{"id": `+jsonId(only)+`, "priorities": ["trace", "error"]}`); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", code, resp)
+	}
+	if _, priorities, _ := r.pattern(only.Id()); priorities != Only(Trace, Error) {
+		t.Errorf("expected %s, got %s", Only(Trace, Error), priorities)
+	}
+
+	for _, body := range []string{
+		`{"id": ` + jsonId(only) + `, "priorities": []}`,
+		`{"id": ` + jsonId(only) + `, "priorities": ["loud"]}`,
+		`{"id": ` + jsonId(only) + `, "priorities": ["info"], "min": "info"}`,
+	} {
+		if code, resp := adminRequest(t, h, http.MethodPut, body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d: %s", body, code, resp)
+		}
+	}
+}
+
+func TestAdminHandlerTTL(t *testing.T) {
+	r := NewRegistry(nil)
+	handle := r.Register("github.com/acme", Warn, discardListenerFn)
+	defer handle.Remove()
+
+	h := NewAdminHandler(r)
+	defer h.Close()
+
+	body := `{"id": ` + jsonId(handle) + `, "min": "trace", "ttl": "50ms"}`
+	code, resp := adminRequest(t, h, http.MethodPost, body)
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, resp)
+	}
+
+	var l adminListener
+	if err := json.Unmarshal([]byte(resp), &l); err != nil {
+		t.Fatalf("unable to decode %s: %v", resp, err)
+	}
+	if l.Min != "Trace" || l.Expires == nil {
+		t.Errorf("expected a temporary Trace override, got %+v", l)
+	}
+
+	tr := r.Tracer("github.com/acme")
+	if !tr.Enabled(Trace) {
+		t.Errorf("expected Trace to be enabled during the override")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for tr.Enabled(Trace) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if tr.Enabled(Trace) || !tr.Enabled(Warn) {
+		t.Errorf("expected the override to revert to Warn")
+	}
+
+	info, _ := r.Listener(handle.Id())
+	if info.Min != Warn {
+		t.Errorf("expected min Warn after revert, got %s", info.Min)
+	}
+}
+
+func jsonId(h listenerHandle) string {
+	b, _ := json.Marshal(h.Id())
+	return string(b)
+}
@@ -0,0 +1,165 @@
+package trace
+
+import (
+	"fmt"
+	"time"
+)
+
+// SyncPolicy defines when a LogWriter calls fsync on its log file,
+// see WithSyncPolicy.
+type SyncPolicy struct {
+	onError  bool
+	interval time.Duration
+}
+
+// SyncNever leaves it to the operating system to decide when to write
+// the log file to disk.  This is the default policy.
+var SyncNever = SyncPolicy{}
+
+// SyncOnError flushes and syncs the log file after each Error
+// priority message written by ListenerFn.
+var SyncOnError = SyncPolicy{onError: true}
+
+// SyncEvery returns a SyncPolicy that flushes and syncs the log file
+// once every interval.
+func SyncEvery(interval time.Duration) SyncPolicy {
+	return SyncPolicy{interval: interval}
+}
+
+// WithBuffer returns a LogWriterOption that buffers writes to the log
+// file in memory, using a buffer of size bytes.  The buffer is written
+// to the file when it fills, once every flushInterval if flushInterval
+// is > 0, and when Flush, Sync, or Close is called.
+func WithBuffer(size int, flushInterval time.Duration) LogWriterOption {
+	return func(w *LogWriter) error {
+		if size < 1 {
+			return fmt.Errorf("WithBuffer: size must be >= 1")
+		}
+		if flushInterval < 0 {
+			return fmt.Errorf("WithBuffer: flushInterval must be >= 0")
+		}
+		w.bufSize = size
+		w.flushInterval = flushInterval
+		return nil
+	}
+}
+
+// WithFlushOnError returns a LogWriterOption that flushes the buffer
+// after each Error priority message written by ListenerFn, so the
+// message reaches the log file even if the program then crashes.
+func WithFlushOnError() LogWriterOption {
+	return func(w *LogWriter) error {
+		w.flushOnError = true
+		return nil
+	}
+}
+
+// WithSyncPolicy returns a LogWriterOption that sets when the log file
+// is synced to disk.
+func WithSyncPolicy(policy SyncPolicy) LogWriterOption {
+	return func(w *LogWriter) error {
+		if policy.interval < 0 {
+			return fmt.Errorf("WithSyncPolicy: interval must be >= 0")
+		}
+		w.syncPolicy = policy
+		return nil
+	}
+}
+
+// Flush writes any buffered output to the log file.
+func (w *LogWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+// Sync writes any buffered output to the log file, and commits the
+// file to stable storage.
+func (w *LogWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sync()
+}
+
+// flush implements Flush, the caller must hold w.mu.  If the buffer
+// cannot be written its contents are dropped and the log file is
+// closed, so that the next write reopens it.
+func (w *LogWriter) flush() error {
+	if w.buf == nil || w.fh == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.discardFile()
+		return err
+	}
+	return nil
+}
+
+// sync implements Sync, the caller must hold w.mu.
+func (w *LogWriter) sync() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.fh == nil || w.fixed {
+		return nil
+	}
+	return w.fh.Sync()
+}
+
+// start launches a goroutine to periodically flush or sync the log
+// file, if the options require one.
+func (w *LogWriter) start() {
+	if (w.bufSize == 0 || w.flushInterval == 0) && w.syncPolicy.interval == 0 {
+		return
+	}
+
+	w.done = make(chan struct{})
+	w.running.Add(1)
+	go w.run(w.done)
+}
+
+// stop stops the goroutine launched by start, if any.
+func (w *LogWriter) stop() {
+	w.mu.Lock()
+	done := w.done
+	w.done = nil
+	w.mu.Unlock()
+
+	if done != nil {
+		close(done)
+		w.running.Wait()
+	}
+}
+
+// run flushes the buffer every w.flushInterval, and syncs the log file
+// every w.syncPolicy.interval, until done is closed.
+func (w *LogWriter) run(done <-chan struct{}) {
+	defer w.running.Done()
+
+	var flushC, syncC <-chan time.Time
+	if w.bufSize > 0 && w.flushInterval > 0 {
+		t := time.NewTicker(w.flushInterval)
+		defer t.Stop()
+		flushC = t.C
+	}
+	if w.syncPolicy.interval > 0 {
+		t := time.NewTicker(w.syncPolicy.interval)
+		defer t.Stop()
+		syncC = t.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-flushC:
+			if err := w.Flush(); err != nil {
+				w.reportError(err)
+			}
+		case <-syncC:
+			if err := w.Sync(); err != nil {
+				w.reportError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package trace
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readLog(t *testing.T, w *LogWriter) string {
+	b, err := ioutil.ReadFile(w.Name())
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", w.Name(), err)
+	}
+	return string(b)
+}
+
+func TestLogWriterBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithBuffer(4096, 0), WithFlushOnError())
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	w.ListenerFn(time.Now(), "buffer", Info, "first")
+	if s := readLog(t, w); s != "" {
+		t.Errorf("expected buffered output to be pending, got %q", s)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s := readLog(t, w); !strings.HasSuffix(s, "first\n") {
+		t.Errorf("expected Flush to write the buffer, got %q", s)
+	}
+
+	w.ListenerFn(time.Now(), "buffer", Warn, "second")
+	if s := readLog(t, w); strings.Contains(s, "second") {
+		t.Errorf("expected Warn to remain buffered, got %q", s)
+	}
+
+	w.ListenerFn(time.Now(), "buffer", Error, "third")
+	if s := readLog(t, w); !strings.Contains(s, "second\n") || !strings.HasSuffix(s, "third\n") {
+		t.Errorf("expected Error to flush the buffer, got %q", s)
+	}
+
+	w.ListenerFn(time.Now(), "buffer", Info, "fourth")
+
+	name := w.Name()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 4 || !strings.HasSuffix(string(b), "fourth\n") {
+		t.Errorf("expected Close to flush all 4 lines, got %q", string(b))
+	}
+}
+
+func TestLogWriterFlushInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithBuffer(4096, 10*time.Millisecond), WithSyncPolicy(SyncEvery(20*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	w.ListenerFn(time.Now(), "buffer", Info, "hello")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for readLog(t, w) == "" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if s := readLog(t, w); !strings.HasSuffix(s, "hello\n") {
+		t.Errorf("expected the buffer to be flushed periodically, got %q", s)
+	}
+}
+
+func TestLogWriterSyncOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithBuffer(4096, 0), WithSyncPolicy(SyncOnError))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	w.ListenerFn(time.Now(), "buffer", Error, "crash imminent")
+
+	if s := readLog(t, w); !strings.HasSuffix(s, "crash imminent\n") {
+		t.Errorf("expected SyncOnError to flush the buffer, got %q", s)
+	}
+
+	if err := w.Sync(); err != nil {
+		t.Errorf("unexpected Sync error: %v", err)
+	}
+}
+
+func TestLogWriterFlushOnErrorFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var errs []error
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn,
+		WithBuffer(4096, 0),
+		WithFlushOnError(),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	w.ListenerFn(time.Now(), "buffer", Info, "pending")
+
+	// simulate an I/O error by closing the file out from under the buffer
+	w.mu.Lock()
+	w.fh.Close()
+	w.mu.Unlock()
+
+	w.ListenerFn(time.Now(), "buffer", Error, "crash")
+
+	if len(errs) != 1 {
+		t.Errorf("expected the failed flush to be reported, got %v", errs)
+	}
+	if stats := w.Stats(); stats.LastError == nil || stats.DroppedBytes == 0 {
+		t.Errorf("expected the failed flush to be recorded, got %+v", stats)
+	}
+}
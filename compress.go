@@ -0,0 +1,125 @@
+package trace
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Compressor compresses old log files, it is used with the
+// WithCompression LogWriterOption.  The standard library only provides
+// GzipCompressor, other formats such as zstd may be added by
+// implementing this interface.
+type Compressor interface {
+	// Ext returns the extension to append to the name of a compressed
+	// file, e.g., ".gz"
+	Ext() string
+	// NewWriter returns a WriteCloser that compresses data written to
+	// it onto w.  Close must flush any buffered data but must not
+	// close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// gzipCompressor implements Compressor using compress/gzip.
+type gzipCompressor struct {
+	level int
+}
+
+// GzipCompressor returns a Compressor producing gzip files with the
+// specified compression level, e.g., gzip.DefaultCompression.
+func GzipCompressor(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+func (c gzipCompressor) Ext() string {
+	return ".gz"
+}
+
+func (c gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+// WithCompression returns a LogWriterOption that compresses a log
+// file with c once the LogWriter has switched away from it, either
+// because of WithMaxSize or because a time-based name has changed.
+// Compression runs in a background goroutine, writes to the new log
+// file never wait for it, but Close does.  Any error encountered is
+// passed to errFn, which may be nil; it is called from the background
+// goroutine.
+func WithCompression(c Compressor, errFn func(path string, err error)) LogWriterOption {
+	return func(w *LogWriter) error {
+		if c == nil {
+			return fmt.Errorf("WithCompression: compressor is nil")
+		}
+		w.compressor = c
+		w.compressErrFn = errFn
+		return nil
+	}
+}
+
+// compress starts compressing path in the background, if compression
+// is enabled.  The caller must hold w.mu.
+func (w *LogWriter) compress(path string) {
+	if w.compressor == nil {
+		return
+	}
+
+	w.compressWg.Add(1)
+	go func() {
+		defer w.compressWg.Done()
+		if err := compressFile(w.compressor, path, w.perm); err != nil && w.compressErrFn != nil {
+			w.compressErrFn(path, err)
+		}
+	}()
+}
+
+// compressFile compresses path to path+c.Ext(), removing path once
+// the compressed file is complete.  The compressed file is written
+// under a temporary name, so a partial file is never mistaken for a
+// complete one.
+func compressFile(c Compressor, path string, perm os.FileMode) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if perm == 0 {
+		if fi, err := src.Stat(); err == nil {
+			perm = fi.Mode().Perm()
+		}
+	}
+
+	dst := path + c.Ext()
+	tmp := dst + ".tmp"
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			fh.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	cw, err := c.NewWriter(fh)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(cw, src); err != nil {
+		return err
+	}
+	if err = cw.Close(); err != nil {
+		return err
+	}
+	if err = fh.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,141 @@
+package trace
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogWriterCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var errs []error
+	errFn := func(path string, err error) {
+		errs = append(errs, err)
+	}
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithMaxSize(10), WithCompression(GzipCompressor(gzip.BestSpeed), errFn))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	for _, line := range []string{"first....\n", "second...\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errs) != 0 {
+		t.Errorf("unexpected compression errors: %v", errs)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "test.log.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Ext(files[0]) != ".gz" {
+		t.Fatalf("expected a single compressed backup, got %v", files)
+	}
+
+	fh, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	zr, err := gzip.NewReader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "first....\n" {
+		t.Errorf("expected compressed backup to hold the first line, got %q", string(b))
+	}
+}
+
+// failingCompressor implements a Compressor that always fails.
+type failingCompressor struct{}
+
+func (c failingCompressor) Ext() string {
+	return ".fail"
+}
+
+func (c failingCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nil, errors.New("compression failed")
+}
+
+func TestLogWriterCompressionError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var w *LogWriter
+	var mu sync.Mutex
+	var failed []string
+	release := make(chan struct{})
+	errFn := func(path string, err error) {
+		// calling back into the LogWriter while Close waits for
+		// compression must not deadlock
+		<-release
+		w.Stats()
+		mu.Lock()
+		failed = append(failed, path)
+		mu.Unlock()
+	}
+
+	w, err = NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithMaxSize(10), WithCompression(failingCompressor{}, errFn))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("012345678\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close deadlocked with the compression error handler")
+	}
+
+	if len(failed) != 1 {
+		t.Fatalf("expected 1 compression error, got %d", len(failed))
+	}
+	if _, err := os.Stat(failed[0]); err != nil {
+		t.Errorf("expected the uncompressed backup to remain: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(files) != 0 {
+		t.Errorf("expected temporary files to be removed, got %v", files)
+	}
+}
@@ -0,0 +1,98 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ANSI escape sequences used by ColorFormatterFn.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+)
+
+// ConsoleFormatterFn defines a FormatterFn suited to a developer
+// terminal, aligning the priority and path columns:
+//
+//	15:04:05.000 WARN  github.com/acme/db slow query
+var ConsoleFormatterFn = mustTemplateFormatterFn("%{time:15:04:05.000} %{level:upper,5} %{path} %{msg}")
+
+// mustTemplateFormatterFn calls NewTemplateFormatterFn, panicking if
+// pattern does not compile.
+func mustTemplateFormatterFn(pattern string) FormatterFn {
+	fn, err := NewTemplateFormatterFn(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return fn
+}
+
+// ColorFormatterFn returns a FormatterFn that colors the output of
+// fmtFn according to its priority: Error is red, Warn is yellow, and
+// Debug and Trace are dim.  Info is not colored.
+func ColorFormatterFn(fmtFn FormatterFn) FormatterFn {
+	return func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+		msg := fmtFn(t, path, priority, format, args...)
+
+		var color string
+		switch priority {
+		case Error:
+			color = ansiRed
+		case Warn:
+			color = ansiYellow
+		case Debug, Trace:
+			color = ansiDim
+		default:
+			return msg
+		}
+
+		if strings.HasSuffix(msg, "\n") {
+			return color + msg[:len(msg)-1] + ansiReset + "\n"
+		}
+		return color + msg + ansiReset
+	}
+}
+
+// NewConsoleLogWriter initializes a new LogWriter writing to fh,
+// usually os.Stdout or os.Stderr, using fmtFn to format messages, or
+// ConsoleFormatterFn if fmtFn is nil.  The output is colored with
+// ColorFormatterFn when fh is a terminal and the NO_COLOR environment
+// variable is not set.  fh is never rotated or reopened, and Close
+// flushes any buffered output but does not close fh.
+func NewConsoleLogWriter(fh *os.File, fmtFn FormatterFn) (*LogWriter, error) {
+	if fh == nil {
+		return nil, fmt.Errorf("NewConsoleLogWriter: specified filehandle is nil")
+	}
+	if fmtFn == nil {
+		fmtFn = ConsoleFormatterFn
+	}
+	if useColor(fh) {
+		fmtFn = ColorFormatterFn(fmtFn)
+	}
+	return NewFileLogWriter(fh, fmtFn, borrowFile)
+}
+
+// borrowFile marks the filehandle given to NewFileLogWriter as owned
+// by the caller, so that it is written to but never closed.
+func borrowFile(w *LogWriter) error {
+	w.fixed = true
+	w.borrowed = true
+	return nil
+}
+
+// useColor returns true if fh is a terminal and the NO_COLOR
+// environment variable is not set to a non-empty value.
+func useColor(fh *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := fh.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
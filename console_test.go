@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestColorFormatterFn(t *testing.T) {
+	fn := ColorFormatterFn(func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+		return format
+	})
+
+	tests := []struct {
+		priority Priority
+		msg      string
+		expected string
+	}{
+		{Error, "a", "\x1b[31ma\x1b[0m"},
+		{Warn, "b", "\x1b[33mb\x1b[0m"},
+		{Info, "c", "c"},
+		{Debug, "d\n", "\x1b[2md\x1b[0m\n"},
+		{Trace, "e", "\x1b[2me\x1b[0m"},
+	}
+
+	for _, v := range tests {
+		if s := fn(time.Time{}, "", v.priority, v.msg); s != v.expected {
+			t.Errorf("%s: expected %q, got %q", v.priority, v.expected, s)
+		}
+	}
+}
+
+func TestConsoleFormatterFn(t *testing.T) {
+	tm := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+
+	for _, v := range []struct {
+		priority Priority
+		expected string
+	}{
+		{Info, "15:04:05.000 INFO  github.com/acme/db hello"},
+		{Error, "15:04:05.000 ERROR github.com/acme/db hello"},
+	} {
+		if s := ConsoleFormatterFn(tm, "github.com/acme/db", v.priority, "hello"); s != v.expected {
+			t.Errorf("expected [%s], got [%s]", v.expected, s)
+		}
+	}
+}
+
+func TestNewConsoleLogWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fh, err := os.Create(filepath.Join(dir, "console.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if useColor(fh) {
+		t.Errorf("expected color to be disabled for a regular file")
+	}
+
+	w, err := NewConsoleLogWriter(fh, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.ListenerFn(time.Now(), "console", Error, "hello")
+
+	if err := w.Close(); err != nil {
+		t.Errorf("unexpected error closing the console writer: %v", err)
+	}
+	if _, err := fh.WriteString("after close\n"); err != nil {
+		t.Errorf("expected fh to remain open after Close, got %v", err)
+	}
+	fh.Close()
+
+	b, err := ioutil.ReadFile(fh.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); strings.Contains(s, "\x1b[") || !strings.HasSuffix(s, " ERROR console hello\nafter close\n") {
+		t.Errorf("unexpected console output %q", s)
+	}
+
+	if _, err := NewConsoleLogWriter(nil, nil); err == nil {
+		t.Errorf("expected an error for a nil filehandle")
+	}
+}
+
+func TestUseColorNoColor(t *testing.T) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		t.Skipf("no terminal available: %v", err)
+	}
+	defer tty.Close()
+
+	t.Setenv("NO_COLOR", "1")
+
+	if useColor(tty) {
+		t.Errorf("expected NO_COLOR to disable color")
+	}
+}
@@ -0,0 +1,209 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event describes a single trace message as delivered to an
+// EventListener.  A single Event is shared by all of the listeners
+// that receive it, and the message is formatted at most once.
+type Event struct {
+	// Time is the time the event was created
+	Time time.Time
+	// Path is the trace path the event was logged to
+	Path string
+	// Priority is the priority level of the event
+	Priority Priority
+	// Format and Args are an fmt.Sprintf compatible message
+	Format string
+	Args   []interface{}
+	// Fields holds any key/value pairs attached to the event
+	Fields Fields
+
+	once sync.Once
+	msg  string
+	str  string
+}
+
+// render formats the message and fields, it is called once by
+// Message or String.
+func (e *Event) render() {
+	e.msg = fmt.Sprintf(e.Format, e.Args...)
+	if len(e.Fields) == 0 {
+		e.str = e.msg
+	} else {
+		e.str = e.msg + " " + e.Fields.String()
+	}
+}
+
+// Message returns the result of formatting Format and Args, it does
+// not include Fields.  The result is computed on the first call and
+// reused afterwards.
+func (e *Event) Message() string {
+	e.once.Do(func() { e.render() })
+	return e.msg
+}
+
+// String returns Message followed by Fields, if there are any,
+// separated by a space.  The result is computed on the first call
+// and reused afterwards.
+func (e *Event) String() string {
+	e.once.Do(func() { e.render() })
+	return e.str
+}
+
+// EventListener is implemented by listeners that want to receive the
+// Fields attached to an event.
+type EventListener interface {
+	Listen(e *Event)
+}
+
+// EventListenerFn adapts a function to the EventListener interface.
+type EventListenerFn func(e *Event)
+
+// Listen calls fn(e).
+func (fn EventListenerFn) Listen(e *Event) {
+	fn(e)
+}
+
+// Listen adapts a ListenerFn to the EventListener interface.  The
+// original format and args of e are passed to fn, its Fields are not.
+func (fn ListenerFn) Listen(e *Event) {
+	fn(e.Time, e.Path, e.Priority, e.Format, e.Args...)
+}
+
+// eventVerb is the format used to pass an Event to a FormatterFn.
+const eventVerb = "%v"
+
+// formatEvent calls fmtFn with the format "%v" and e as the only
+// argument, so that fmtFn can reuse the message shared by every
+// listener receiving e, and render its Fields.
+func formatEvent(fmtFn FormatterFn, e *Event) string {
+	return fmtFn(e.Time, e.Path, e.Priority, eventVerb, e)
+}
+
+// EventFromArgs returns the Event passed to a FormatterFn by an
+// EventListener such as LogWriter or MemLog, or nil if format and
+// args hold an ordinary message.
+func EventFromArgs(format string, args []interface{}) *Event {
+	if format != eventVerb || len(args) != 1 {
+		return nil
+	}
+	e, _ := args[0].(*Event)
+	return e
+}
+
+// SplitFields returns the original format, args, and Fields of an
+// Event passed to a FormatterFn.  If format and args do not hold an
+// Event they are returned unchanged with nil Fields.
+func SplitFields(format string, args []interface{}) (string, []interface{}, Fields) {
+	if e := EventFromArgs(format, args); e != nil {
+		return e.Format, e.Args, e.Fields
+	}
+	return format, args, nil
+}
+
+// Field is a key/value pair attached to an event.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Any returns a Field for an arbitrary value.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// String returns a Field holding a string.
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int returns a Field holding an int.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int64 returns a Field holding an int64.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Uint64 returns a Field holding a uint64.
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Float64 returns a Field holding a float64.
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool returns a Field holding a bool.
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration returns a Field holding a time.Duration.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Time returns a Field holding a time.Time.
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err returns a Field with the key "error" holding err.
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// Fields is a list of key/value pairs attached to an event.
+type Fields []Field
+
+// String renders the fields as space separated key=value pairs,
+// quoting values that are empty or that contain spaces, quotes, '='
+// or control characters.
+func (f Fields) String() string {
+	var b strings.Builder
+	for i, field := range f {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(field.Key)
+		b.WriteByte('=')
+		b.WriteString(quoteValue(fieldValue(field.Value)))
+	}
+	return b.String()
+}
+
+// fieldValue returns the string representation of a Field value.
+func fieldValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quoteValue quotes s if it is empty or if it contains characters
+// that would make a key=value pair ambiguous.
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
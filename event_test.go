@@ -0,0 +1,218 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldsString(t *testing.T) {
+	tm, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05-07:00")
+	if err != nil {
+		t.Fatalf("unable to parse test input time: %v", err)
+	}
+
+	fields := Fields{
+		String("request", "abc123"),
+		String("user", "jim robinson"),
+		String("empty", ""),
+		Int("n", 42),
+		Float64("ratio", 0.5),
+		Bool("ok", true),
+		Duration("elapsed", 1500*time.Millisecond),
+		Time("at", tm),
+		Err(errors.New("no such file")),
+		Any("eq", "a=b"),
+	}
+
+	e := `request=abc123 user="jim robinson" empty="" n=42 ratio=0.5 ok=true elapsed=1.5s at=2006-01-02T15:04:05-07:00 error="no such file" eq="a=b"`
+	if s := fields.String(); s != e {
+		t.Errorf("expected [%s], got [%s]", e, s)
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	fields := Fields{Int("n", 1)}
+	e := &Event{Format: "hello %s", Args: []interface{}{"world"}, Fields: fields}
+
+	var format string
+	var args []interface{}
+	formatEvent(func(t time.Time, p string, n Priority, f string, a ...interface{}) string {
+		format, args = f, a
+		return ""
+	}, e)
+
+	if s := fmt.Sprintf(format, args...); s != "hello world n=1" {
+		t.Errorf("expected [hello world n=1], got [%s]", s)
+	}
+
+	f, a, fs := SplitFields(format, args)
+	if f != "hello %s" || len(a) != 1 || a[0] != "world" || len(fs) != 1 || fs[0] != fields[0] {
+		t.Errorf("unexpected SplitFields result: %q %v %v", f, a, fs)
+	}
+
+	f, a, fs = SplitFields("hello %v", []interface{}{"world"})
+	if f != "hello %v" || len(a) != 1 || fs != nil {
+		t.Errorf("unexpected SplitFields result without fields: %q %v %v", f, a, fs)
+	}
+}
+
+func TestListenerFnOriginalArgs(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var formats []string
+	var args [][]interface{}
+	for i := 0; i < 2; i++ {
+		handle := r.Register("original", Info, func(t time.Time, p string, n Priority, format string, a ...interface{}) {
+			formats = append(formats, format)
+			args = append(args, a)
+		})
+		defer handle.Remove()
+	}
+	eh := r.RegisterListener(Prefix("original"), AtLeast(Info), EventListenerFn(func(e *Event) {}))
+	defer eh.Remove()
+
+	r.Tracer("original").With(Int("n", 1)).Infof("user %s logged in", "bob")
+
+	if len(formats) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(formats))
+	}
+	for i := range formats {
+		if formats[i] != "user %s logged in" || len(args[i]) != 1 || args[i][0] != "bob" {
+			t.Errorf("[%d] expected the original format and args, got %q %v", i, formats[i], args[i])
+		}
+	}
+}
+
+func TestEventMessageOnce(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var seen []string
+	for i := 0; i < 3; i++ {
+		handle := r.RegisterListener(Prefix("once"), AtLeast(Info), EventListenerFn(func(e *Event) {
+			seen = append(seen, formatEvent(DefaultFormatterFn, e))
+		}))
+		defer handle.Remove()
+	}
+
+	calls := 0
+	arg := stringerFn(func() string {
+		calls++
+		return "world"
+	})
+
+	if m, ok := r.M("once", Info); ok {
+		T(m, "hello %v", arg)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected message to be formatted once, formatted %d times", calls)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(seen))
+	}
+	for i, v := range seen {
+		if !strings.HasSuffix(v, "[once] hello world") {
+			t.Errorf("[%d] unexpected message [%s]", i, v)
+		}
+	}
+}
+
+// stringerFn implements fmt.Stringer, allowing a test to count how
+// often a message argument is formatted.
+type stringerFn func() string
+
+func (fn stringerFn) String() string {
+	return fn()
+}
+
+func TestTracerWith(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var events []*Event
+	eh := r.RegisterListener(Prefix("with"), AtLeast(Info), EventListenerFn(func(e *Event) {
+		events = append(events, e)
+	}))
+	defer eh.Remove()
+
+	var formatted []string
+	fh := r.RegisterListener(Prefix("with"), AtLeast(Info), EventListenerFn(func(e *Event) {
+		formatted = append(formatted, formatEvent(DefaultFormatterFn, e))
+	}))
+	defer fh.Remove()
+
+	tr := r.Tracer("with").With(String("request", "abc")).With(Int("user", 7))
+	tr.Infof("hello %s", "world")
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Path != "with" || e.Priority != Info || e.Message() != "hello world" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if len(e.Fields) != 2 || e.Fields[0].Key != "request" || e.Fields[1].Key != "user" {
+		t.Errorf("unexpected event fields: %v", e.Fields)
+	}
+
+	if len(formatted) != 1 || !strings.HasSuffix(formatted[0], "] hello world request=abc user=7") {
+		t.Errorf("expected DefaultFormatterFn to render fields, got %v", formatted)
+	}
+}
+
+func TestLogWriterFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn)
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	r := NewRegistry(nil)
+	handle := w.Register(r, Prefix(""), AllPriorities)
+	defer handle.Remove()
+
+	r.Tracer("fields").With(String("request", "abc")).Warnf("hello")
+
+	b, err := ioutil.ReadFile(w.Name())
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", w.Name(), err)
+	}
+	if !strings.HasSuffix(string(b), "[fields] hello request=abc\n") {
+		t.Errorf("expected fields in log line, got [%s]", string(b))
+	}
+}
+
+func TestMemLogFields(t *testing.T) {
+	mlog, err := NewMemLog(DefaultMemLogLimits, 10, DefaultFormatterFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mlog.Close()
+
+	r := NewRegistry(nil)
+	handle := mlog.Register(r, Prefix(""), AllPriorities)
+	defer handle.Remove()
+
+	r.Tracer("fields").With(Int("n", 1)).Errorf("hello")
+	mlog.wg.Wait()
+
+	b, err := ioutil.ReadAll(mlog.Reader(Error, 0, DESC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(b), "[fields] hello n=1\n") {
+		t.Errorf("expected fields in memlog entry, got [%s]", string(b))
+	}
+}
@@ -0,0 +1,51 @@
+package trace
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// WithFileLock returns a LogWriterOption that takes an exclusive
+// advisory lock (flock) on the log file around each write, so that
+// processes appending to the same file never interleave their
+// messages, however large.  When combined with WithBuffer the lock is
+// held for each flush of the buffer, and the buffer is flushed early
+// rather than splitting a message across two flushes.  Every process
+// writing to the file must use WithFileLock.  Size-based rotation is
+// not coordinated between processes.
+func WithFileLock() LogWriterOption {
+	return func(w *LogWriter) error {
+		if !fileLockSupported {
+			return fmt.Errorf("WithFileLock: file locking is not supported on this platform")
+		}
+		w.flock = true
+		return nil
+	}
+}
+
+// lockedFile implements an io.Writer that holds an exclusive lock on
+// fh for the duration of each Write.
+type lockedFile struct {
+	fh *os.File
+}
+
+func (f lockedFile) Write(p []byte) (n int, err error) {
+	if err = lockFile(f.fh); err != nil {
+		return 0, fmt.Errorf("unable to lock %s: %v", f.fh.Name(), err)
+	}
+	n, err = f.fh.Write(p)
+	if uerr := unlockFile(f.fh); err == nil && uerr != nil {
+		err = fmt.Errorf("unable to unlock %s: %v", f.fh.Name(), uerr)
+	}
+	return n, err
+}
+
+// file returns the io.Writer for the current log file, which takes
+// a lock around each write when WithFileLock is used.
+func (w *LogWriter) file() io.Writer {
+	if w.flock {
+		return lockedFile{w.fh}
+	}
+	return w.fh
+}
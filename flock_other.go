@@ -0,0 +1,20 @@
+//go:build !unix
+
+package trace
+
+import (
+	"errors"
+	"os"
+)
+
+const fileLockSupported = false
+
+var errFileLock = errors.New("file locking is not supported on this platform")
+
+func lockFile(fh *os.File) error {
+	return errFileLock
+}
+
+func unlockFile(fh *os.File) error {
+	return errFileLock
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package trace
+
+import (
+	"os"
+	"syscall"
+)
+
+const fileLockSupported = true
+
+// lockFile takes an exclusive advisory lock on fh, waiting until it
+// is available.
+func lockFile(fh *os.File) error {
+	for {
+		err := syscall.Flock(int(fh.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+}
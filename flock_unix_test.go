@@ -0,0 +1,121 @@
+//go:build unix
+
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	flockWorkers  = 4
+	flockMessages = 200
+	flockSize     = 128 * 1024
+)
+
+// flockMessage returns message i of worker, a line of flockSize bytes
+// made up of a header and the repeated worker letter.
+func flockMessage(worker, i int) []byte {
+	header := fmt.Sprintf("%d %d ", worker, i)
+	return []byte(header + strings.Repeat(string(rune('a'+worker)), flockSize-len(header)-1) + "\n")
+}
+
+// TestLogWriterFileLockHelper is run as a subprocess by
+// TestLogWriterFileLock, it writes flockMessages messages to the log
+// file named by TRACE_FLOCK_PATH.
+func TestLogWriterFileLockHelper(t *testing.T) {
+	path := os.Getenv("TRACE_FLOCK_PATH")
+	if path == "" {
+		t.Skip("run by TestLogWriterFileLock")
+	}
+
+	var worker int
+	fmt.Sscan(os.Getenv("TRACE_FLOCK_WORKER"), &worker)
+
+	opts := []LogWriterOption{WithFileLock()}
+	if worker%2 == 1 {
+		opts = append(opts, WithBuffer(flockSize*3/2, 0))
+	}
+
+	w, err := NewLogWriter(filepath.Dir(path), filepath.Base(path), 0644, DefaultFormatterFn, opts...)
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	for i := 0; i < flockMessages; i++ {
+		if _, err := w.Write(flockMessage(worker, i)); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+}
+
+func TestLogWriterFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+
+	cmds := make([]*exec.Cmd, flockWorkers)
+	output := make([]bytes.Buffer, flockWorkers)
+	for i := range cmds {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLogWriterFileLockHelper$")
+		cmd.Env = append(os.Environ(), "TRACE_FLOCK_PATH="+path, fmt.Sprintf("TRACE_FLOCK_WORKER=%d", i))
+		cmd.Stdout = &output[i]
+		cmd.Stderr = &output[i]
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("unable to start worker %d: %v", i, err)
+		}
+		cmds[i] = cmd
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("worker %d failed: %v\n%s", i, err, output[i].String())
+		}
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fh.Close()
+
+	next := make([]int, flockWorkers)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, flockSize), flockSize)
+	for scanner.Scan() {
+		var worker, i int
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d ", &worker, &i); err != nil || worker < 0 || worker >= flockWorkers {
+			t.Fatalf("malformed line: %.40q", scanner.Text())
+		}
+		if line := scanner.Text() + "\n"; line != string(flockMessage(worker, i)) {
+			t.Fatalf("worker %d message %d was interleaved with another write", worker, i)
+		}
+		if i != next[worker] {
+			t.Fatalf("worker %d: expected message %d, got %d", worker, next[worker], i)
+		}
+		next[worker]++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	for worker, n := range next {
+		if n != flockMessages {
+			t.Errorf("worker %d: expected %d messages, got %d", worker, flockMessages, n)
+		}
+	}
+}
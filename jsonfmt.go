@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JSONFormatterFn defines a FormatterFn producing one JSON object per
+// event, suitable for writing JSON lines with a LogWriter.  The object
+// has the members "time" (time.RFC3339Nano), "path", "priority",
+// "msg", and when the event was logged with a format, "format" and
+// "args".  Any Fields are included in a "fields" object.
+var JSONFormatterFn FormatterFn = func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+	var msg string
+	if e := EventFromArgs(format, args); e != nil {
+		msg = e.Message()
+	}
+	format, args, fields := SplitFields(format, args)
+	if msg == "" {
+		msg = fmt.Sprintf(format, args...)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSONValue(&b, t.Format(time.RFC3339Nano))
+	b.WriteString(`,"path":`)
+	writeJSONValue(&b, path)
+	b.WriteString(`,"priority":`)
+	writeJSONValue(&b, priority.String())
+	b.WriteString(`,"msg":`)
+	writeJSONValue(&b, msg)
+
+	if format != "" {
+		b.WriteString(`,"format":`)
+		writeJSONValue(&b, format)
+		b.WriteString(`,"args":[`)
+		for i, arg := range args {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(&b, arg)
+		}
+		b.WriteByte(']')
+	}
+
+	if len(fields) > 0 {
+		b.WriteString(`,"fields":{`)
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(&b, f.Key)
+			b.WriteByte(':')
+			writeJSONValue(&b, f.Value)
+		}
+		b.WriteByte('}')
+	}
+
+	b.WriteByte('}')
+	return b.String()
+}
+
+// writeJSONValue writes v to b as JSON.  Errors, durations and times
+// are written as strings, and values that cannot be marshaled are
+// written as the string produced by fmt.Sprint.
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case time.Time:
+		v = x.Format(time.RFC3339Nano)
+	case time.Duration:
+		v = x.String()
+	case error:
+		v = x.Error()
+	case json.Marshaler:
+	case fmt.Stringer:
+		v = x.String()
+	}
+
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		enc.Encode(fmt.Sprint(v))
+	}
+
+	// Encode terminates each value with a newline
+	b.Truncate(b.Len() - 1)
+}
@@ -0,0 +1,54 @@
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJSONFormatterFn(t *testing.T) {
+	tm, err := time.Parse(time.RFC3339Nano, "2006-01-02T15:04:05.999999999-07:00")
+	if err != nil {
+		t.Fatalf("unable to parse test input time: %v", err)
+	}
+
+	s := JSONFormatterFn(tm, "github.com/jimrobinson/trace", Warn, "%s <%d>", "hello,\x01\t\"world\"\n", 3)
+	e := `{"time":"2006-01-02T15:04:05.999999999-07:00","path":"github.com/jimrobinson/trace","priority":"Warn","msg":"hello,\u0001\t\"world\"\n <3>","format":"%s <%d>","args":["hello,\u0001\t\"world\"\n",3]}`
+	if s != e {
+		t.Errorf("expected [%s], got [%s]", e, s)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+		t.Errorf("unable to decode %s: %v", s, err)
+	} else if decoded["msg"] != "hello,\x01\t\"world\"\n <3>" {
+		t.Errorf("unexpected decoded msg %q", decoded["msg"])
+	}
+}
+
+func TestJSONFormatterFnFields(t *testing.T) {
+	tm, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05-07:00")
+	if err != nil {
+		t.Fatalf("unable to parse test input time: %v", err)
+	}
+
+	s := formatEvent(JSONFormatterFn, &Event{
+		Time:     tm,
+		Path:     "fields",
+		Priority: Error,
+		Format:   "failed after %v",
+		Args:     []interface{}{2 * time.Second},
+		Fields:   Fields{String("request", "abc"), Int("n", 1), Err(errors.New("boom")), Any("ch", make(chan int))},
+	})
+
+	e := `{"time":"2006-01-02T15:04:05-07:00","path":"fields","priority":"Error","msg":"failed after 2s","format":"failed after %v","args":["2s"],"fields":{"request":"abc","n":1,"error":"boom","ch":"`
+	if len(s) < len(e) || s[:len(e)] != e {
+		t.Errorf("expected prefix [%s], got [%s]", e, s)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+		t.Errorf("unable to decode %s: %v", s, err)
+	}
+}
@@ -0,0 +1,182 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LevelClause sets the Priority for the paths equal to or below
+// Prefix.  An empty Prefix applies to every path.
+type LevelClause struct {
+	Prefix   string
+	Priority Priority
+}
+
+// LevelSpec is a list of LevelClause, as parsed by ParseLevelSpec.
+type LevelSpec []LevelClause
+
+// ParseLevelSpec parses a comma separated list of path=priority
+// clauses, e.g.:
+//
+//	github.com/acme/db=debug,github.com/acme=info,*=warn
+//
+// The path "*" applies to every path, and a clause with no path, such
+// as "warn", is the same as "*=warn".  Priorities are parsed with
+// ParsePriority.  When more than one clause applies to a path the
+// clause with the longest path wins, so in the example above
+// github.com/acme/db logs at Debug, the rest of github.com/acme at
+// Info, and every other path at Warn.
+func ParseLevelSpec(s string) (LevelSpec, error) {
+	var spec LevelSpec
+	seen := make(map[string]bool)
+
+	for _, clause := range strings.Split(s, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+
+		path, level := "*", clause
+		if i := strings.LastIndexByte(clause, '='); i >= 0 {
+			path, level = strings.TrimSpace(clause[:i]), strings.TrimSpace(clause[i+1:])
+		}
+
+		if path == "" {
+			return nil, fmt.Errorf("invalid trace spec clause %q: missing path", clause)
+		}
+		if path == "*" {
+			path = ""
+		} else if strings.ContainsAny(path, "*?[") {
+			return nil, fmt.Errorf("invalid trace spec clause %q: path must be a prefix or \"*\"", clause)
+		}
+
+		if seen[path] {
+			return nil, fmt.Errorf("invalid trace spec clause %q: path repeats an earlier clause", clause)
+		}
+		seen[path] = true
+
+		priority, err := ParsePriority(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace spec clause %q: unknown priority %q", clause, level)
+		}
+
+		spec = append(spec, LevelClause{Prefix: path, Priority: priority})
+	}
+
+	return spec, nil
+}
+
+// String returns the spec in the form accepted by ParseLevelSpec.
+func (spec LevelSpec) String() string {
+	clauses := make([]string, len(spec))
+	for i, c := range spec {
+		path := c.Prefix
+		if path == "" {
+			path = "*"
+		}
+		clauses[i] = path + "=" + strings.ToLower(c.Priority.String())
+	}
+	return strings.Join(clauses, ",")
+}
+
+// listeners returns the listeners needed to route events to el
+// according to spec.  Each clause excludes the paths of the more
+// specific clauses below it, so an event is delivered at most once.
+func (spec LevelSpec) listeners(el EventListener) []listenerSpec {
+	var add []listenerSpec
+	for _, c := range spec {
+		if c.Priority == None {
+			continue
+		}
+
+		var exclude []Pattern
+		for _, o := range spec {
+			if o.Prefix != c.Prefix && matchPrefix(c.Prefix, o.Prefix) {
+				exclude = append(exclude, Prefix(o.Prefix))
+			}
+		}
+
+		pattern := Prefix(c.Prefix)
+		if len(exclude) > 0 {
+			pattern = Exclude(pattern, exclude...)
+		}
+
+		add = append(add, listenerSpec{pattern, AtLeast(c.Priority), el})
+	}
+	return add
+}
+
+// LevelController installs the listeners needed to route events to
+// a sink according to a LevelSpec, and allows the spec to be replaced
+// while the program runs.
+type LevelController struct {
+	registry *Registry
+	sink     EventListener
+	// mu guards spec and handles
+	mu      sync.Mutex
+	spec    LevelSpec
+	handles []listenerHandle
+}
+
+// NewLevelController returns a LevelController that will install
+// listeners for sink in r, or in DefaultRegistry if r is nil.  No
+// listeners are installed until Apply or Set is called.
+func NewLevelController(r *Registry, sink EventListener) *LevelController {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return &LevelController{registry: r, sink: sink}
+}
+
+// LevelsFromEnv parses the value of the environment variable name
+// with ParseLevelSpec and applies it to a new LevelController.  If
+// the variable is not set the controller is returned with no
+// listeners installed.
+func LevelsFromEnv(r *Registry, name string, sink EventListener) (*LevelController, error) {
+	c := NewLevelController(r, sink)
+	if err := c.Set(os.Getenv(name)); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return c, nil
+}
+
+// Set parses s with ParseLevelSpec and applies the result.  If s
+// cannot be parsed the current spec is left in place.
+func (c *LevelController) Set(s string) error {
+	spec, err := ParseLevelSpec(s)
+	if err != nil {
+		return err
+	}
+	c.Apply(spec)
+	return nil
+}
+
+// Apply replaces the listeners installed for the current spec with
+// those needed for spec.  The change is atomic, a concurrent call to
+// M will see either the old or the new listeners, never a mixture.
+func (c *LevelController) Apply(spec LevelSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remove := make([]uint64, len(c.handles))
+	for i, h := range c.handles {
+		remove[i] = h.id
+	}
+
+	c.handles = c.registry.replace(remove, spec.listeners(c.sink))
+	c.spec = spec
+}
+
+// Spec returns the spec most recently applied.
+func (c *LevelController) Spec() LevelSpec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spec
+}
+
+// Remove uninstalls all of the listeners installed by c.
+func (c *LevelController) Remove() {
+	c.Apply(nil)
+}
@@ -0,0 +1,134 @@
+package trace
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevelSpec(t *testing.T) {
+	spec, err := ParseLevelSpec(" github.com/acme/db=debug, github.com/acme=INFO,*=warn,")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := LevelSpec{
+		{"github.com/acme/db", Debug},
+		{"github.com/acme", Info},
+		{"", Warn},
+	}
+	if len(spec) != len(expected) {
+		t.Fatalf("expected %d clauses, got %d", len(expected), len(spec))
+	}
+	for i, v := range expected {
+		if spec[i] != v {
+			t.Errorf("[%d] expected %+v got %+v", i, v, spec[i])
+		}
+	}
+
+	if s := spec.String(); s != "github.com/acme/db=debug,github.com/acme=info,*=warn" {
+		t.Errorf("unexpected String() result: %s", s)
+	}
+
+	spec, err = ParseLevelSpec("error")
+	if err != nil || len(spec) != 1 || spec[0] != (LevelClause{"", Error}) {
+		t.Errorf("expected a bare priority to apply to every path, got %+v %v", spec, err)
+	}
+
+	for _, v := range []struct {
+		spec   string
+		clause string
+	}{
+		{"github.com/acme=verbose", `"github.com/acme=verbose"`},
+		{"a=info,=debug", `"=debug"`},
+		{"a=info,a=debug", `"a=debug"`},
+		{"a/*/b=info", `"a/*/b=info"`},
+	} {
+		_, err := ParseLevelSpec(v.spec)
+		if err == nil {
+			t.Errorf("%s: expected an error", v.spec)
+		} else if !strings.Contains(err.Error(), v.clause) {
+			t.Errorf("%s: expected error to name clause %s, got %v", v.spec, v.clause, err)
+		}
+	}
+}
+
+func TestLevelController(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var events []*Event
+	sink := EventListenerFn(func(e *Event) {
+		events = append(events, e)
+	})
+
+	c := NewLevelController(r, sink)
+	if err := c.Set("github.com/acme/db=debug,github.com/acme/noisy=none,github.com/acme=info,*=warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		priority Priority
+		expected bool
+	}{
+		{"github.com/acme/db", Debug, true},
+		{"github.com/acme/db", Error, true},
+		{"github.com/acme/db/pool", Trace, false},
+		{"github.com/acme", Info, true},
+		{"github.com/acme/http", Debug, false},
+		{"github.com/acme/noisy", Error, false},
+		{"github.com/other", Info, false},
+		{"github.com/other", Warn, true},
+	}
+
+	for i, v := range tests {
+		events = nil
+		r.Tracer(v.path).Logf(v.priority, "hello")
+		if v.expected && len(events) != 1 {
+			t.Errorf("[%d] %s %s: expected 1 event, got %d", i, v.path, v.priority, len(events))
+		} else if !v.expected && len(events) != 0 {
+			t.Errorf("[%d] %s %s: expected no events, got %d", i, v.path, v.priority, len(events))
+		}
+	}
+
+	if err := c.Set("*=verbose"); err == nil {
+		t.Errorf("expected an error for an invalid spec")
+	}
+	if s := c.Spec().String(); s != "github.com/acme/db=debug,github.com/acme/noisy=none,github.com/acme=info,*=warn" {
+		t.Errorf("expected an invalid spec to leave the current spec in place, got %s", s)
+	}
+
+	if err := c.Set("*=error"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.Listeners()); n != 1 {
+		t.Errorf("expected 1 listener after re-applying the spec, got %d", n)
+	}
+
+	c.Remove()
+	if n := len(r.Listeners()); n != 0 {
+		t.Errorf("expected no listeners after Remove, got %d", n)
+	}
+}
+
+func TestLevelsFromEnv(t *testing.T) {
+	const name = "TRACE_LEVELS_TEST"
+	os.Setenv(name, "levels=info")
+	defer os.Unsetenv(name)
+
+	r := NewRegistry(nil)
+	c, err := LevelsFromEnv(r, name, EventListenerFn(func(e *Event) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Remove()
+
+	if !r.Tracer("levels/a").Enabled(Info) {
+		t.Errorf("expected Info to be enabled for levels/a")
+	}
+
+	os.Setenv(name, "levels=loud")
+	if _, err := LevelsFromEnv(r, name, EventListenerFn(func(e *Event) {})); err == nil || !strings.Contains(err.Error(), name) {
+		t.Errorf("expected an error naming %s, got %v", name, err)
+	}
+}
@@ -2,6 +2,8 @@ package trace
 
 import (
 	"fmt"
+	"reflect"
+	"runtime"
 	"time"
 )
 
@@ -20,23 +22,76 @@ type FormatterFn func(t time.Time, path string, priority Priority, format string
 // a message format "[<time>][<path>] <message>", where time uses the
 // format time.RFC3339.
 var DefaultFormatterFn = func(t time.Time, id string, priority Priority, format string, args ...interface{}) string {
-	return fmt.Sprintf("[%s][%s] %s", t.Format(time.RFC3339), id, fmt.Sprintf(format, args...))
+	return fmt.Sprintf("[%s][%s] %s", t.Format(time.RFC3339), id, formatMessage(format, args))
+}
+
+// formatMessage returns the result of formatting format and args,
+// reusing the message of a shared Event if possible.
+func formatMessage(format string, args []interface{}) string {
+	if e := EventFromArgs(format, args); e != nil {
+		return e.String()
+	}
+	return fmt.Sprintf(format, args...)
 }
 
 // listener defines a ListenerFn that should be called when a trace
-// path starts with prefix and when it has a Priority level >= min.
+// path matches pattern and when its Priority level is in priorities.
+// Prefix patterns are the common case, so they are recorded in
+// prefix and pattern is left nil, allowing M to avoid an interface
+// call.
 type listener struct {
-	prefix string
-	min    Priority
-	fn     ListenerFn
+	id         uint64
+	prefix     string
+	pattern    Pattern
+	priorities Priorities
+	// fn is set when the listener was registered as a ListenerFn,
+	// allowing T to call it without first building an Event.
+	fn ListenerFn
+	el EventListener
+}
+
+func newListener(id uint64, pattern Pattern, priorities Priorities, el EventListener) *listener {
+	l := &listener{
+		id:         id,
+		priorities: priorities,
+		el:         el,
+	}
+	if fn, ok := el.(ListenerFn); ok {
+		l.fn = fn
+	}
+	if p, ok := pattern.(prefixPattern); ok {
+		l.prefix = string(p)
+	} else {
+		l.pattern = pattern
+	}
+	return l
+}
+
+// Pattern returns the Pattern the listener was installed with.
+func (l *listener) Pattern() Pattern {
+	if l.pattern != nil {
+		return l.pattern
+	}
+	return Prefix(l.prefix)
+}
+
+// sink returns a description of the listener function, for use in
+// ListenerInfo.
+func (l *listener) sink() string {
+	if l.fn != nil {
+		if f := runtime.FuncForPC(reflect.ValueOf(l.fn).Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+	return fmt.Sprintf("%T", l.el)
 }
 
-func newListener(prefix string, min Priority, fn ListenerFn) *listener {
-	return &listener{
-		prefix: prefix,
-		min:    min,
-		fn:     fn,
+// String returns a description of the listener pattern.
+func (l *listener) String() string {
+	if l.pattern != nil {
+		return l.pattern.String()
 	}
+	return l.prefix
 }
 
 // listenerMatch is produced by function M and is used to
@@ -46,6 +101,7 @@ type listenerMatch struct {
 	path     string
 	priority Priority
 	fn       ListenerFn
+	el       EventListener
 }
 
 func newListenerMatch(path string, priority Priority, listener *listener) listenerMatch {
@@ -53,5 +109,6 @@ func newListenerMatch(path string, priority Priority, listener *listener) listen
 		path:     path,
 		priority: priority,
 		fn:       listener.fn,
+		el:       listener.el,
 	}
 }
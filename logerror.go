@@ -0,0 +1,137 @@
+package trace
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// LogWriterStats counts the writes a LogWriter was unable to make to
+// its log file.
+type LogWriterStats struct {
+	// FailedWrites is the number of writes that could not be made
+	// to the log file
+	FailedWrites uint64
+	// DroppedBytes is the number of bytes that could not be written
+	// to either the log file or the fallback writer
+	DroppedBytes uint64
+	// FallbackWrites is the number of failed writes that were written
+	// to the fallback writer instead
+	FallbackWrites uint64
+	// LastError is the most recent error, or nil
+	LastError error
+}
+
+// WithErrorHandler returns a LogWriterOption that calls errFn with
+// each error encountered writing to the log file.  The LogWriter is
+// locked while errFn runs, so errFn must not call the LogWriter.
+func WithErrorHandler(errFn func(err error)) LogWriterOption {
+	return func(w *LogWriter) error {
+		w.errFn = errFn
+		return nil
+	}
+}
+
+// WithFallback returns a LogWriterOption that writes to fallback, for
+// example os.Stderr, any message that could not be written to the log
+// file.
+func WithFallback(fallback io.Writer) LogWriterOption {
+	return func(w *LogWriter) error {
+		if fallback == nil {
+			return fmt.Errorf("WithFallback: fallback is nil")
+		}
+		w.fallback = fallback
+		return nil
+	}
+}
+
+// WithRetryBackoff returns a LogWriterOption that, once a write to
+// the log file has failed, waits min before trying the file again,
+// doubling the wait after each further failure up to max.  Messages
+// written while waiting go to the fallback writer, if any.  Without
+// this option the file is retried on every write.
+func WithRetryBackoff(min, max time.Duration) LogWriterOption {
+	return func(w *LogWriter) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("WithRetryBackoff: expected 0 < min <= max")
+		}
+		w.retryMin = min
+		w.retryMax = max
+		return nil
+	}
+}
+
+// Stats returns the counts of failed writes.
+func (w *LogWriter) Stats() LogWriterStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// writeFailed records the failure to write p to the log file, writing
+// it to the fallback writer if there is one.  When attempted is true
+// the failure was a new attempt to write to the file, and the retry
+// backoff is extended.  The caller must hold w.mu.
+func (w *LogWriter) writeFailed(p []byte, err error, attempted bool) {
+	w.stats.FailedWrites++
+	w.stats.LastError = err
+
+	if attempted && w.retryMin > 0 {
+		if w.retryDelay == 0 {
+			w.retryDelay = w.retryMin
+		} else if w.retryDelay *= 2; w.retryDelay > w.retryMax {
+			w.retryDelay = w.retryMax
+		}
+		w.retryAt = time.Now().Add(w.retryDelay)
+	}
+
+	if w.fallback != nil {
+		if _, ferr := w.fallback.Write(p); ferr == nil {
+			w.stats.FallbackWrites++
+		} else {
+			w.stats.DroppedBytes += uint64(len(p))
+		}
+	} else {
+		w.stats.DroppedBytes += uint64(len(p))
+	}
+
+	if attempted && w.errFn != nil {
+		w.errFn(err)
+	}
+}
+
+// reportError records an error that is not tied to a single write,
+// such as a failure to flush the buffer.
+func (w *LogWriter) reportError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handleError(err)
+}
+
+// handleError implements reportError, the caller must hold w.mu.
+func (w *LogWriter) handleError(err error) {
+	w.stats.LastError = err
+	if w.errFn != nil {
+		w.errFn(err)
+	}
+}
+
+// discardFile closes the log file after a failed write, so that the
+// next attempt reopens it, and discards any buffered output, which
+// can no longer be written.  The caller must hold w.mu.
+func (w *LogWriter) discardFile() {
+	if w.buf != nil {
+		w.stats.DroppedBytes += uint64(w.buf.Buffered())
+		if w.fixed {
+			w.buf.Reset(w.file())
+		} else {
+			w.buf = nil
+		}
+	}
+	if w.fixed || w.fh == nil {
+		return
+	}
+	w.fh.Close()
+	w.fh = nil
+	w.stat = nil
+}
@@ -0,0 +1,189 @@
+package trace
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogWriterErrors(t *testing.T) {
+	parent, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(parent)
+
+	dir := filepath.Join(parent, "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	var fallback bytes.Buffer
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn,
+		WithCheckInterval(0),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+		WithFallback(&fallback),
+		WithRetryBackoff(50*time.Millisecond, time.Second))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	w.ListenerFn(time.Now(), "errors", Info, "written")
+
+	// simulate an unwritable log file by removing its directory
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	w.ListenerFn(time.Now(), "errors", Info, "fallback one")
+	w.ListenerFn(time.Now(), "errors", Info, "fallback two")
+
+	if len(errs) != 1 {
+		t.Errorf("expected the error handler to be called once during the backoff, got %d: %v", len(errs), errs)
+	}
+
+	stats := w.Stats()
+	if stats.FailedWrites != 2 || stats.FallbackWrites != 2 || stats.DroppedBytes != 0 || stats.LastError == nil {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if s := fallback.String(); !strings.Contains(s, "fallback one\n") || !strings.Contains(s, "fallback two\n") {
+		t.Errorf("expected failed messages in the fallback writer, got %q", s)
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := w.Write([]byte("recovered\n")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("LogWriter did not recover: %+v", w.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "recovered\n" {
+		t.Errorf("expected the recreated log file to hold the recovered write, got %q", string(b))
+	}
+}
+
+func TestLogWriterDroppedBytes(t *testing.T) {
+	parent, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(parent)
+
+	dir := filepath.Join(parent, "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithCheckInterval(0))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	os.RemoveAll(dir)
+
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Errorf("expected Write to report an error")
+	}
+
+	if stats := w.Stats(); stats.FailedWrites != 1 || stats.DroppedBytes != 5 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if w.Name() != "" {
+		t.Errorf("expected Name to return \"\" while the log file is unavailable")
+	}
+}
+
+func TestLogWriterBufferedRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn,
+		WithBuffer(64, 0),
+		WithCheckInterval(time.Hour),
+		WithRetryBackoff(10*time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	w.Write([]byte("lost\n"))
+
+	// simulate an I/O error, such as a full disk, by closing the file
+	// out from under the buffer
+	w.mu.Lock()
+	w.fh.Close()
+	w.mu.Unlock()
+
+	if err := w.Flush(); err == nil {
+		t.Fatalf("expected Flush to fail")
+	}
+	if stats := w.Stats(); stats.DroppedBytes != 5 {
+		t.Errorf("expected the buffered bytes to be dropped, got %+v", stats)
+	}
+
+	for _, msg := range []string{"one\n", "two\n"} {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatalf("expected the LogWriter to recover, got %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("expected the LogWriter to recover, got %v", err)
+		}
+	}
+
+	// a failed write backs off, and then reopens the file
+	w.mu.Lock()
+	w.fh.Close()
+	w.mu.Unlock()
+
+	// larger than the buffer, so it is written straight to the file
+	if _, err := w.Write([]byte(strings.Repeat("3", 100) + "\n")); err == nil {
+		t.Errorf("expected a write to the closed file to fail")
+	}
+	if _, err := w.Write([]byte("four\n")); err == nil {
+		t.Errorf("expected a write during the backoff to fail")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := w.Write([]byte("five\n")); err != nil {
+		t.Fatalf("expected the LogWriter to recover after the backoff, got %v", err)
+	}
+	w.Flush()
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "one\ntwo\nfive\n" {
+		t.Errorf("expected %q, got %q", "one\ntwo\nfive\n", string(b))
+	}
+}
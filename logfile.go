@@ -1,7 +1,9 @@
 package trace
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,8 +21,17 @@ type LogWriter struct {
 	name string
 	// useTimeFmt flags whether or not path is a time-based filename.
 	useTimeFmt bool
+	// fixed flags that fh is not a regular file, such as a terminal or
+	// a pipe, and should never be reopened by path
+	fixed bool
+	// borrowed flags that fh belongs to the caller of
+	// NewConsoleLogWriter, Close flushes it but leaves it open
+	borrowed bool
 	// perm
 	perm os.FileMode
+	// dirPerm, when not zero, is used to create missing directories,
+	// see WithMkdirAll
+	dirPerm os.FileMode
 	// fh is the open filehandle to the current logfile.
 	fh *os.File
 	// stat is the os.FileInfo of fh.Name(), when fh is open
@@ -29,11 +40,148 @@ type LogWriter struct {
 	mu *sync.Mutex
 	// fmtFn implements the log message formatter
 	fmtFn FormatterFn
+	// size holds the size of the current logfile
+	size int64
+	// maxSize, maxBackups and maxAge define the size based rotation
+	// and retention policy, they are disabled when zero
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	// rotateAt and rotateDelay back off rotation after a backup could
+	// not be created
+	rotateAt    time.Time
+	rotateDelay time.Duration
+	// compressor, when not nil, is used to compress log files in the
+	// background once the LogWriter has switched away from them
+	compressor Compressor
+	// compressErrFn is called with any error encountered compressing
+	// a log file
+	compressErrFn func(path string, err error)
+	// compressWg tracks in-flight compression
+	compressWg sync.WaitGroup
+	// path holds the current log path, and when useTimeFmt is true
+	// boundary holds the time at which it must next be recomputed
+	path     string
+	boundary time.Time
+	// checkInterval sets how often to check whether the log file has
+	// been renamed, checked holds the time of the last check
+	checkInterval time.Duration
+	checked       time.Time
+	// buf, when not nil, buffers writes to fh, see WithBuffer
+	buf           *bufio.Writer
+	bufSize       int
+	flushInterval time.Duration
+	flushOnError  bool
+	syncPolicy    SyncPolicy
+	// done is closed by Close to stop the goroutine started by start,
+	// and running tracks that goroutine
+	done    chan struct{}
+	running sync.WaitGroup
+	// closed is set by Close
+	closed bool
+	// flock is set by WithFileLock
+	flock bool
+	// symlink, when not empty, names a link kept pointing to the
+	// current log file, see WithSymlink
+	symlink string
+	// errFn, fallback, and the retry settings implement the handling
+	// of failed writes, see WithErrorHandler, WithFallback, and
+	// WithRetryBackoff
+	errFn      func(err error)
+	fallback   io.Writer
+	retryMin   time.Duration
+	retryMax   time.Duration
+	retryDelay time.Duration
+	retryAt    time.Time
+	stats      LogWriterStats
+}
+
+// DefaultCheckInterval is how often a LogWriter checks whether its log
+// file has been renamed or removed, unless WithCheckInterval is used.
+var DefaultCheckInterval = time.Second
+
+// WithCheckInterval returns a LogWriterOption setting how often the
+// LogWriter checks whether its log file has been renamed or removed,
+// in which case a new file is opened.  An interval of 0 checks before
+// every write.
+func WithCheckInterval(interval time.Duration) LogWriterOption {
+	return func(w *LogWriter) error {
+		if interval < 0 {
+			return fmt.Errorf("WithCheckInterval: interval must be >= 0")
+		}
+		w.checkInterval = interval
+		return nil
+	}
+}
+
+// WithMkdirAll returns a LogWriterOption that creates the log
+// directory, and any missing parents, using perm.  Directories are
+// also created as needed when the log file is reopened, for example
+// after the directory was removed, or when a time-based name contains
+// a path separator, such as "2006/01/02.log".
+func WithMkdirAll(perm os.FileMode) LogWriterOption {
+	return func(w *LogWriter) error {
+		if perm == 0 {
+			return fmt.Errorf("WithMkdirAll: perm must not be 0")
+		}
+		w.dirPerm = perm
+		return nil
+	}
+}
+
+// LogWriterOption configures an optional LogWriter feature, options
+// are passed to NewLogWriter, NewTimeLogWriter, or NewFileLogWriter.
+type LogWriterOption func(w *LogWriter) error
+
+// applyOptions calls each of opts on w.
+func (w *LogWriter) applyOptions(opts []LogWriterOption) error {
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return err
+		}
+	}
+	if w.fh == nil {
+		if err := w.checkDir(); err != nil {
+			return err
+		}
+	}
+	if w.bufSize > 0 && w.fh != nil {
+		w.buf = bufio.NewWriterSize(w.file(), w.bufSize)
+	}
+	return nil
+}
+
+// begin starts any background work the options require, and adds w
+// to the LogWriters reopened by ReopenAll.  It is called once w has
+// been successfully opened.
+func (w *LogWriter) begin() {
+	w.start()
+	w.track()
+}
+
+// checkDir ensures w.dir is a directory, creating it if WithMkdirAll
+// was used.
+func (w *LogWriter) checkDir() error {
+	if w.dirPerm != 0 {
+		if err := os.MkdirAll(w.dir, w.dirPerm); err != nil {
+			return fmt.Errorf("unable to create dir %s: %v", w.dir, err)
+		}
+	}
+	stat, err := os.Stat(w.dir)
+	if err != nil {
+		return fmt.Errorf("unable to stat dir %s: %v", w.dir, err)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("specified dir path is not a directory: %s", w.dir)
+	}
+	return nil
 }
 
 // NewFileLogWriter initializes a new LogWriter using an already open *os.File
-// as the destination for output.
-func NewFileLogWriter(fh *os.File, fmtFn FormatterFn) (w *LogWriter, err error) {
+// as the destination for output.  If fh is not a regular file, e.g.,
+// os.Stdout attached to a terminal or a pipe, it will never be
+// reopened by name.
+func NewFileLogWriter(fh *os.File, fmtFn FormatterFn, opts ...LogWriterOption) (w *LogWriter, err error) {
 	if fh == nil {
 		return nil, fmt.Errorf("NewFileLogWriter: specified filehandle is nil")
 	}
@@ -48,11 +196,23 @@ func NewFileLogWriter(fh *os.File, fmtFn FormatterFn) (w *LogWriter, err error)
 		dir:        dir,
 		name:       name,
 		useTimeFmt: false,
+		fixed:      !fi.Mode().IsRegular(),
+		perm:       fi.Mode().Perm(),
 		fh:         fh,
 		stat:       fi,
 		mu:         &sync.Mutex{},
 		fmtFn:      fmtFn,
+		size:       fi.Size(),
+		path:       fh.Name(),
+
+		checkInterval: DefaultCheckInterval,
+		checked:       time.Now(),
+	}
+
+	if err = w.applyOptions(opts); err != nil {
+		return nil, err
 	}
+	w.begin()
 
 	return w, nil
 }
@@ -61,16 +221,9 @@ func NewFileLogWriter(fh *os.File, fmtFn FormatterFn) (w *LogWriter, err error)
 // in directory dir.  The supplied perm is used to set the permissions
 // of the log file when it is created.  The supplied FormatterFn will
 // be used to format the messages;  adding a newline to the message
-// if one is not produced by fmtFn.
-func NewLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn) (w *LogWriter, err error) {
-	stat, err := os.Stat(dir)
-	if err != nil {
-		return nil, fmt.Errorf("unable to stat dir %s: %v", dir, err)
-	}
-	if !stat.IsDir() {
-		return nil, fmt.Errorf("specified dir path is not a directory: %s", dir)
-	}
-
+// if one is not produced by fmtFn.  An error is returned if the log
+// file cannot be opened.
+func NewLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn, opts ...LogWriterOption) (w *LogWriter, err error) {
 	w = &LogWriter{
 		dir:        dir,
 		name:       name,
@@ -80,9 +233,18 @@ func NewLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn) (w *Log
 		stat:       nil,
 		mu:         &sync.Mutex{},
 		fmtFn:      fmtFn,
+
+		checkInterval: DefaultCheckInterval,
 	}
-	err = w.checkPath()
-	return w, err
+	if err = w.applyOptions(opts); err != nil {
+		return nil, err
+	}
+	if err = w.checkPath(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	w.begin()
+	return w, nil
 }
 
 // NewTimeLogWriter initializes a new LogWriter.  The name is expected
@@ -91,16 +253,9 @@ func NewLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn) (w *Log
 // year, month, and day  The supplied perm is used to set the permissions
 // of the log file when it is created.  The supplied FormatterFn will
 // be used to format the messages;  adding a newline to the message
-// if one is not produced by fmtFn.
-func NewTimeLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn) (w *LogWriter, err error) {
-	stat, err := os.Stat(dir)
-	if err != nil {
-		return nil, fmt.Errorf("unable to stat dir %s: %v", dir, err)
-	}
-	if !stat.IsDir() {
-		return nil, fmt.Errorf("specified dir path is not a directory: %s", dir)
-	}
-
+// if one is not produced by fmtFn.  An error is returned if the log
+// file cannot be opened.
+func NewTimeLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn, opts ...LogWriterOption) (w *LogWriter, err error) {
 	w = &LogWriter{
 		dir:        dir,
 		name:       name,
@@ -110,9 +265,18 @@ func NewTimeLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn) (w
 		stat:       nil,
 		mu:         &sync.Mutex{},
 		fmtFn:      fmtFn,
+
+		checkInterval: DefaultCheckInterval,
+	}
+	if err = w.applyOptions(opts); err != nil {
+		return nil, err
 	}
-	err = w.checkPath()
-	return w, err
+	if err = w.checkPath(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	w.begin()
+	return w, nil
 }
 
 // ListenerFn provides a hook to register a LogWriter with the trace
@@ -120,8 +284,17 @@ func NewTimeLogWriter(dir, name string, perm os.FileMode, fmtFn FormatterFn) (w
 // message, adding a newline if one is not produced by the FormatterFn,
 // and writing the result to the current log filepath.
 func (w *LogWriter) ListenerFn(t time.Time, path string, priority Priority, entry string, args ...interface{}) {
-	msg := w.fmtFn(t, path, priority, entry, args...)
+	w.writeMessage(priority, w.fmtFn(t, path, priority, entry, args...))
+}
 
+// Listen implements EventListener, writing e with the LogWriter FormatterFn.
+func (w *LogWriter) Listen(e *Event) {
+	w.writeMessage(e.Priority, formatEvent(w.fmtFn, e))
+}
+
+// writeMessage writes msg to the current log filepath, adding a
+// newline if msg does not end with one.
+func (w *LogWriter) writeMessage(priority Priority, msg string) {
 	var buf []byte
 	if strings.HasSuffix(msg, "\n") {
 		buf = []byte(msg)
@@ -132,7 +305,32 @@ func (w *LogWriter) ListenerFn(t time.Time, path string, priority Priority, entr
 		buf[n-1] = '\n'
 	}
 
-	w.Write(buf)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.write(buf); err != nil {
+		return
+	}
+	if priority >= Error && priority != None {
+		var err error
+		if w.syncPolicy.onError {
+			err = w.sync()
+		} else if w.flushOnError {
+			err = w.flush()
+		}
+		if err != nil {
+			w.handleError(err)
+		}
+	}
+}
+
+// Register installs w in r for paths matched by pattern, accepting
+// only the Priority levels in priorities.  If r is nil then
+// DefaultRegistry is used.
+func (w *LogWriter) Register(r *Registry, pattern Pattern, priorities Priorities) listenerHandle {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return r.RegisterListener(pattern, priorities, w)
 }
 
 func (w *LogWriter) Name() string {
@@ -147,56 +345,190 @@ func (w *LogWriter) Name() string {
 func (w *LogWriter) Write(p []byte) (n int, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	return w.write(p)
+}
+
+// write implements Write, the caller must hold w.mu.  If the log file
+// cannot be written the failure is recorded, p is written to the
+// fallback writer if one has been configured, and the log file is
+// closed so that it is reopened by the next attempt.
+func (w *LogWriter) write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, fmt.Errorf("LogWriter is closed")
+	}
+
+	now := time.Now()
+	if now.Before(w.retryAt) {
+		err = fmt.Errorf("log file unavailable until %s: %v", w.retryAt.Format(time.RFC3339Nano), w.stats.LastError)
+		w.writeFailed(p, err, false)
+		return 0, err
+	}
+
+	if n, err = w.writeFile(p); err != nil {
+		w.writeFailed(p[n:], err, true)
+		w.discardFile()
+		return n, err
+	}
+
+	w.retryDelay = 0
+	return n, nil
+}
+
+// writeFile writes p to the current log file, the caller must hold
+// w.mu.
+func (w *LogWriter) writeFile(p []byte) (n int, err error) {
 	if err = w.checkPath(); err != nil {
 		return 0, err
 	}
-	return w.fh.Write(p)
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize && !w.fixed && !time.Now().Before(w.rotateAt) {
+		if err = w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if w.buf != nil {
+		if w.flock && len(p) > w.buf.Available() && w.buf.Buffered() > 0 {
+			// flush whole messages, rather than splitting p across
+			// two locked writes
+			if err = w.buf.Flush(); err != nil {
+				return 0, err
+			}
+		}
+		n, err = w.buf.Write(p)
+	} else {
+		n, err = w.file().Write(p)
+	}
+	w.size += int64(n)
+	return n, err
 }
 
+// Close flushes any buffered output and closes the current log file,
+// and waits for any in-flight compression of old log files to finish.
 func (w *LogWriter) Close() (err error) {
+	w.untrack()
+	w.stop()
+
 	w.mu.Lock()
-	defer w.mu.Unlock()
-	if w.fh != nil {
-		err = w.fh.Close()
+	w.closed = true
+	if w.fh != nil && w.borrowed {
+		err = w.flush()
+	} else if w.fh != nil {
+		err = w.closeFile()
 		w.stat = nil
 	}
+	w.mu.Unlock()
+
+	// wait without holding w.mu, compressErrFn may call w
+	w.compressWg.Wait()
+	return err
+}
+
+// closeFile flushes any buffered output to w.fh and closes it, the
+// caller must hold w.mu.
+func (w *LogWriter) closeFile() error {
+	var err error
+	if w.buf != nil {
+		err = w.buf.Flush()
+	}
+	if cerr := w.fh.Close(); err == nil {
+		err = cerr
+	}
 	return err
 }
 
-// checkPath ensures w.fh is open and pointing to the correct filename
+// checkPath ensures w.fh is open and pointing to the correct filename.
+// A time-based filename is only recomputed once the current time
+// period has ended, and the file is only checked for having been
+// renamed or truncated once every w.checkInterval, if it is not
+// negative.
 func (w *LogWriter) checkPath() error {
+	if w.fixed {
+		return nil
+	}
 
-	var path string
-	if w.useTimeFmt {
-		path = filepath.Join(w.dir, time.Now().Format(w.name))
-	} else {
-		path = filepath.Join(w.dir, w.name)
+	now := time.Now()
+
+	path := w.path
+	if path == "" || (w.useTimeFmt && !now.Before(w.boundary)) {
+		if w.useTimeFmt {
+			path = filepath.Join(w.dir, now.Format(w.name))
+			w.boundary = nextBoundary(w.name, now)
+		} else {
+			path = filepath.Join(w.dir, w.name)
+		}
+		w.path = path
 	}
 
 	// if the filehandle has not yet been opened, or if it is open but path
 	// has changed
 	var err error
-	if w.fh == nil || w.fh.Name() != path {
+	if w.fh == nil {
 		err = w.openFile(path)
-	} else {
-		// if a previously opened file has been renamed
-		var stat os.FileInfo
-		stat, err = os.Stat(path)
-		if err != nil || !os.SameFile(w.stat, stat) {
-			err = w.openFile(path)
+	} else if prev := w.fh.Name(); prev != path {
+		if err = w.openFile(path); err == nil {
+			w.compress(prev)
+			w.removeExpired()
 		}
+	} else if w.checkInterval >= 0 && now.Sub(w.checked) >= w.checkInterval {
+		// if a previously opened file has been renamed or truncated
+		w.checked = now
+		err = w.checkFile(path)
 	}
 
 	return err
 }
 
+// nextBoundary returns the earliest time after t at which the result
+// of t.Format(layout) may change.  It returns t itself if layout
+// includes fractions of a second, so the name is always recomputed,
+// and a time far in the future if layout does not depend on the time.
+func nextBoundary(layout string, t time.Time) time.Time {
+	// probe a reference time to find the smallest unit that changes
+	// the formatted value
+	ref := time.Date(2001, 2, 3, 4, 5, 6, 0, t.Location())
+	changes := func(next time.Time) bool {
+		return ref.Format(layout) != next.Format(layout)
+	}
+
+	y, m, d := t.Date()
+	switch {
+	case changes(ref.Add(time.Millisecond)):
+		return t
+	case changes(ref.Add(time.Second)):
+		return t.Truncate(time.Second).Add(time.Second)
+	case changes(ref.Add(time.Minute)):
+		return time.Date(y, m, d, t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	case changes(ref.Add(time.Hour)):
+		return time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+	case changes(ref.Add(12 * time.Hour)):
+		// the layout includes AM/PM
+		if t.Hour() < 12 {
+			return time.Date(y, m, d, 12, 0, 0, 0, t.Location())
+		}
+		return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	case changes(ref.AddDate(0, 0, 1)):
+		return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	case changes(ref.AddDate(0, 1, 0)):
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+	case changes(ref.AddDate(1, 0, 0)):
+		return time.Date(y+1, 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t.AddDate(100, 0, 0)
+	}
+}
+
 // openFile calls os.OpenFile on path, if w.fh is not nil
 //  it will be closed before being re-opened.
 func (w *LogWriter) openFile(path string) error {
 	var err error
 
+	if w.dirPerm != 0 {
+		if err = os.MkdirAll(filepath.Dir(path), w.dirPerm); err != nil {
+			return err
+		}
+	}
+
 	if w.fh != nil {
-		w.fh.Close()
+		w.closeFile()
 	}
 
 	w.fh, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.perm)
@@ -204,7 +536,21 @@ func (w *LogWriter) openFile(path string) error {
 		return err
 	}
 
+	if w.bufSize > 0 {
+		w.buf = bufio.NewWriterSize(w.file(), w.bufSize)
+	}
+
+	w.checked = time.Now()
 	w.stat, err = os.Stat(path)
+	if err == nil {
+		w.size = w.stat.Size()
+	}
+
+	if err == nil && w.symlink != "" {
+		if lerr := w.updateSymlink(path); lerr != nil {
+			w.handleError(lerr)
+		}
+	}
 
 	return err
 }
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -195,3 +196,314 @@ func BenchmarkTimeLogWriterListenerFn(b *testing.B) {
 	}
 	b.StopTimer()
 }
+
+func TestLogWriterMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithMaxSize(100), WithMaxBackups(2))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	line := []byte(fmt.Sprintf("%039d\n", 0))
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	// 40 byte lines in a 100 byte file: 2 lines per file, the last 2
+	// of the 4 rotated files are kept
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backups int
+	for _, fi := range files {
+		if fi.Size() > 100 {
+			t.Errorf("%s: expected size <= 100, got %d", fi.Name(), fi.Size())
+		}
+		switch {
+		case fi.Name() == "test.log":
+		case strings.HasPrefix(fi.Name(), "test.log."):
+			backups++
+		default:
+			t.Errorf("unexpected file %s", fi.Name())
+		}
+	}
+	if backups != 2 {
+		t.Errorf("expected 2 backups, got %d", backups)
+	}
+}
+
+func TestLogWriterMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	// files of previous periods, one expired, and an unrelated file
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"2001-01-01.log", "2001-01-02.log", "2001-01-02.log.20010102T000000.000", "notes.txt"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if name == "2001-01-01.log" || name == "notes.txt" {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	w, err := NewTimeLogWriter(dir, "2006-01-02.log", 0644, DefaultFormatterFn, WithMaxSize(10), WithMaxAge(24*time.Hour))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	// exceed the maximum size to trigger a rotation and retention
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("0123456789\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, expected := range map[string]bool{
+		"2001-01-01.log":                     false,
+		"2001-01-02.log":                     true,
+		"2001-01-02.log.20010102T000000.000": true,
+		"notes.txt":                          true,
+		time.Now().Format("2006-01-02.log"):  true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != expected {
+			t.Errorf("%s: expected exists %v, got %v", name, expected, exists)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, time.Now().Format("2006-01-02.log")+".*"))
+	if len(files) != 1 {
+		t.Errorf("expected 1 backup of the current file, got %v", files)
+	}
+}
+
+func TestLogWriterOptionErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, opt := range []LogWriterOption{WithMaxSize(0), WithMaxBackups(0), WithMaxAge(0)} {
+		if _, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, opt); err == nil {
+			t.Errorf("expected an error for an invalid option")
+		}
+	}
+}
+
+func TestNextBoundary(t *testing.T) {
+	tm := time.Date(2006, 1, 31, 15, 4, 5, 999, time.Local)
+
+	for _, v := range []struct {
+		layout   string
+		expected time.Time
+	}{
+		{"2006-01-02.15:04:05.000.log", tm},
+		{"2006-01-02.15:04:05.log", time.Date(2006, 1, 31, 15, 4, 6, 0, time.Local)},
+		{"2006-01-02.15:04.log", time.Date(2006, 1, 31, 15, 5, 0, 0, time.Local)},
+		{"2006-01-02.15.log", time.Date(2006, 1, 31, 16, 0, 0, 0, time.Local)},
+		{"2006-01-02.log", time.Date(2006, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"Monday.log", time.Date(2006, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"2006-01.log", time.Date(2006, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"2006.log", time.Date(2007, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"static.log", tm.AddDate(100, 0, 0)},
+		{"2006-01-02-PM.log", time.Date(2006, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"2006-01-02-03PM.log", time.Date(2006, 1, 31, 16, 0, 0, 0, time.Local)},
+	} {
+		if actual := nextBoundary(v.layout, tm); !actual.Equal(v.expected) {
+			t.Errorf("%s: expected %s, got %s", v.layout, v.expected, actual)
+		}
+	}
+
+	am := time.Date(2006, 1, 31, 9, 4, 5, 999, time.Local)
+	for _, v := range []struct {
+		layout   string
+		expected time.Time
+	}{
+		{"2006-01-02-PM.log", time.Date(2006, 1, 31, 12, 0, 0, 0, time.Local)},
+		{"2006-01-02-pm.log", time.Date(2006, 1, 31, 12, 0, 0, 0, time.Local)},
+		{"2006-01-02.log", time.Date(2006, 2, 1, 0, 0, 0, 0, time.Local)},
+	} {
+		if actual := nextBoundary(v.layout, am); !actual.Equal(v.expected) {
+			t.Errorf("%s at %s: expected %s, got %s", v.layout, am, v.expected, actual)
+		}
+		// the name must not change before the boundary
+		if before := v.expected.Add(-time.Millisecond); before.Format(v.layout) != am.Format(v.layout) {
+			t.Errorf("%s: name changed before the boundary %s", v.layout, v.expected)
+		}
+	}
+}
+
+func TestLogWriterCheckInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, interval := range []time.Duration{0, time.Hour} {
+		name := fmt.Sprintf("%s.log", interval)
+		w, err := NewLogWriter(dir, name, 0644, DefaultFormatterFn, WithCheckInterval(interval))
+		if err != nil {
+			t.Fatalf("unable to open new LogWriter: %v", err)
+		}
+
+		defer w.Close()
+
+		path := filepath.Join(dir, name)
+		if err := os.Rename(path, path+".moved"); err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write([]byte("hello\n"))
+
+		_, err = os.Stat(path)
+		if reopened := err == nil; reopened != (interval == 0) {
+			t.Errorf("interval %s: expected reopened %v, got %v", interval, interval == 0, reopened)
+		}
+	}
+}
+
+func TestLogWriterMkdirAll(t *testing.T) {
+	parent, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(parent)
+
+	dir := filepath.Join(parent, "a", "b")
+	if _, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithMkdirAll(0755))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	if expected := filepath.Join(dir, "test.log"); w.Name() != expected {
+		t.Errorf("expected logpath [%s] got [%s]", expected, w.Name())
+	}
+
+	tw, err := NewTimeLogWriter(parent, "2006/01/02.log", 0644, DefaultFormatterFn, WithMkdirAll(0755))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer tw.Close()
+
+	if expected := filepath.Join(parent, time.Now().Format("2006/01/02.log")); tw.Name() != expected {
+		t.Errorf("expected logpath [%s] got [%s]", expected, tw.Name())
+	}
+}
+
+func TestLogWriterRotateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	renames := 0
+	renameFile = func(oldpath, newpath string) error {
+		renames++
+		return fmt.Errorf("simulated failure")
+	}
+	defer func() { renameFile = os.Rename }()
+
+	var errs []error
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn,
+		WithMaxSize(100),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	line := []byte(fmt.Sprintf("%039d\n", 0))
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	if renames != 1 || len(errs) != 1 {
+		t.Errorf("expected 1 failed rotation to be reported, got %d renames and errors %v", renames, errs)
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(5*len(line)) {
+		t.Errorf("expected every message to be written to the current file, got %d bytes", fi.Size())
+	}
+}
+
+func TestFileLogWriterRotatePerm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewFileLogWriter(fh, DefaultFormatterFn, WithMaxSize(10))
+	if err != nil {
+		t.Fatalf("NewFileLogWriter error: %v", err)
+	}
+
+	defer w.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("012345678\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := filepath.Glob(path + ".*")
+	if len(files) != 1 {
+		t.Fatalf("expected the file to be rotated, got backups %v", files)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("expected the rotated-in file to have mode 0640, got %s", fi.Mode().Perm())
+	}
+}
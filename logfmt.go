@@ -0,0 +1,126 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtFormatterFn defines a FormatterFn producing logfmt lines of
+// the form:
+//
+//	time=2006-01-02T15:04:05.999999999-07:00 level=Info path=github.com/acme msg="hello, world" request=abc
+//
+// where time uses time.RFC3339Nano, level is Priority.String(), and
+// any Fields follow msg.  Values are quoted only when they are empty
+// or contain spaces, quotes, '=' or control characters.  Lines may be
+// parsed back into an Event with ParseLogfmt.
+var LogfmtFormatterFn FormatterFn = func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+	var msg string
+	if e := EventFromArgs(format, args); e != nil {
+		msg = e.Message()
+	}
+	format, args, fields := SplitFields(format, args)
+	if msg == "" {
+		msg = fmt.Sprintf(format, args...)
+	}
+
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(t.Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(priority.String())
+	b.WriteString(" path=")
+	b.WriteString(quoteValue(path))
+	b.WriteString(" msg=")
+	b.WriteString(quoteValue(msg))
+	if len(fields) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(fields.String())
+	}
+	return b.String()
+}
+
+// ParseLogfmt parses a line written by LogfmtFormatterFn into an
+// Event.  The time, level, path, and msg keys populate the matching
+// Event members, the message being recorded as the format "%s" with
+// a single argument.  Any other keys are returned as Fields with
+// string values.  A key with no '=' is given an empty value.
+func ParseLogfmt(line string) (*Event, error) {
+	e := &Event{Format: "%s", Args: []interface{}{""}}
+
+	s := strings.TrimRight(line, "\r\n")
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+
+		end := strings.IndexAny(s, "= \t")
+		if end == 0 {
+			return nil, fmt.Errorf("logfmt: missing key at %q", s)
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		key := s[:end]
+		s = s[end:]
+
+		var value string
+		if strings.HasPrefix(s, "=") {
+			s = s[1:]
+			var err error
+			if value, s, err = parseLogfmtValue(s); err != nil {
+				return nil, fmt.Errorf("logfmt: invalid value for key %q: %v", key, err)
+			}
+		}
+
+		switch key {
+		case "time":
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, fmt.Errorf("logfmt: invalid time %q: %v", value, err)
+			}
+			e.Time = t
+		case "level":
+			p, err := ParsePriority(value)
+			if err != nil {
+				return nil, fmt.Errorf("logfmt: invalid level %q", value)
+			}
+			e.Priority = p
+		case "path":
+			e.Path = value
+		case "msg":
+			e.Args[0] = value
+		default:
+			e.Fields = append(e.Fields, String(key, value))
+		}
+	}
+
+	return e, nil
+}
+
+// parseLogfmtValue returns the value at the start of s, unquoting it
+// if necessary, and the remainder of s.
+func parseLogfmtValue(s string) (value, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:], nil
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err = strconv.Unquote(s[:i+1])
+			return value, s[i+1:], err
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated quoted value %s", s)
+}
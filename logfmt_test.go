@@ -0,0 +1,94 @@
+package trace
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmtFormatterFn(t *testing.T) {
+	tm, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05-07:00")
+	if err != nil {
+		t.Fatalf("unable to parse test input time: %v", err)
+	}
+
+	s := LogfmtFormatterFn(tm, "github.com/jimrobinson/trace", Info, "%s %d", "hello, world!", 3)
+	e := `time=2006-01-02T15:04:05-07:00 level=Info path=github.com/jimrobinson/trace msg="hello, world! 3"`
+	if s != e {
+		t.Errorf("expected [%s], got [%s]", e, s)
+	}
+
+	s = LogfmtFormatterFn(tm, "", Warn, "%s", "said \"hi\"\nthen left")
+	e = `time=2006-01-02T15:04:05-07:00 level=Warn path="" msg="said \"hi\"\nthen left"`
+	if s != e {
+		t.Errorf("expected [%s], got [%s]", e, s)
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	e, err := ParseLogfmt(`time=2006-01-02T15:04:05.5-07:00 level=Error path=github.com/acme msg="a \"b\"\nc" request=abc flag empty=""` + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Time.Format(time.RFC3339Nano) != "2006-01-02T15:04:05.5-07:00" {
+		t.Errorf("unexpected time %s", e.Time)
+	}
+	if e.Priority != Error || e.Path != "github.com/acme" || e.Message() != "a \"b\"\nc" {
+		t.Errorf("unexpected event: %s %s %q", e.Priority, e.Path, e.Message())
+	}
+	if s := e.Fields.String(); s != `request=abc flag="" empty=""` {
+		t.Errorf("unexpected fields [%s]", s)
+	}
+
+	for _, line := range []string{
+		`time=yesterday`,
+		`level=loud`,
+		`msg="unterminated`,
+		`=value`,
+	} {
+		if _, err := ParseLogfmt(line); err == nil {
+			t.Errorf("%s: expected an error", line)
+		}
+	}
+}
+
+func TestLogfmtRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, LogfmtFormatterFn)
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	r := NewRegistry(nil)
+	handle := w.Register(r, Prefix(""), AllPriorities)
+	defer handle.Remove()
+
+	r.Tracer("github.com/acme/db").With(Duration("elapsed", time.Second), String("query", "select 1")).Warnf("slow %s", "query")
+
+	b, err := ioutil.ReadFile(w.Name())
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", w.Name(), err)
+	}
+
+	e, err := ParseLogfmt(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatalf("unable to parse [%s]: %v", string(b), err)
+	}
+	if e.Path != "github.com/acme/db" || e.Priority != Warn || e.Message() != "slow query" {
+		t.Errorf("unexpected event: %s %s %q", e.Path, e.Priority, e.Message())
+	}
+	if s := e.Fields.String(); s != `elapsed=1s query="select 1"` {
+		t.Errorf("unexpected fields [%s]", s)
+	}
+}
@@ -0,0 +1,187 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFmt is the time format used to name the backups created
+// when a LogWriter rotates a file that has reached its maximum size.
+const backupTimeFmt = "20060102T150405.000"
+
+// backupSuffix matches the suffix added to the name of a backup.
+var backupSuffix = regexp.MustCompile(`\.\d{8}T\d{6}\.\d{3}(-\d+)?(\.gz|\.zst)?$`)
+
+// WithMaxSize returns a LogWriterOption that rotates the current log
+// file once writing to it would exceed maxSize bytes.  The file is
+// renamed by appending a timestamp, e.g., "app.log" becomes
+// "app.log.20061017T150405.000", and a new file is opened.  When used
+// with NewTimeLogWriter the file is rotated within a time period, and
+// a new period still starts a new file.
+func WithMaxSize(maxSize int64) LogWriterOption {
+	return func(w *LogWriter) error {
+		if maxSize < 1 {
+			return fmt.Errorf("WithMaxSize: maxSize must be >= 1")
+		}
+		w.maxSize = maxSize
+		return nil
+	}
+}
+
+// WithMaxBackups returns a LogWriterOption that keeps at most
+// maxBackups old log files, deleting the oldest.  Old log files are
+// the backups created by WithMaxSize and, for a LogWriter created with
+// NewTimeLogWriter, the files of previous time periods.  Retention is
+// applied each time the LogWriter switches to a new file.
+func WithMaxBackups(maxBackups int) LogWriterOption {
+	return func(w *LogWriter) error {
+		if maxBackups < 1 {
+			return fmt.Errorf("WithMaxBackups: maxBackups must be >= 1")
+		}
+		w.maxBackups = maxBackups
+		return nil
+	}
+}
+
+// WithMaxAge returns a LogWriterOption that deletes old log files
+// last modified more than maxAge ago.  See WithMaxBackups for the
+// files considered old.
+func WithMaxAge(maxAge time.Duration) LogWriterOption {
+	return func(w *LogWriter) error {
+		if maxAge <= 0 {
+			return fmt.Errorf("WithMaxAge: maxAge must be > 0")
+		}
+		w.maxAge = maxAge
+		return nil
+	}
+}
+
+// rotateRetryMin and rotateRetryMax bound the wait before trying to
+// rotate again after a backup could not be created.
+const (
+	rotateRetryMin = time.Second
+	rotateRetryMax = time.Minute
+)
+
+// renameFile renames a log file to its backup, tests replace it to
+// simulate failures.
+var renameFile = os.Rename
+
+// rotate renames the current log file to a backup and opens a new
+// file in its place.  If the backup cannot be created the error is
+// reported through the error handler, the current file is reopened
+// so writing can continue, and rotation is not tried again until a
+// backoff has passed.  The caller must hold w.mu.
+func (w *LogWriter) rotate() error {
+	path := w.fh.Name()
+
+	w.closeFile()
+	w.fh = nil
+	w.stat = nil
+
+	backup := backupName(path, time.Now())
+	if err := renameFile(path, backup); err != nil {
+		// keep writing to the current file rather than losing messages
+		if oerr := w.openFile(path); oerr != nil {
+			return oerr
+		}
+		if w.rotateDelay == 0 {
+			w.rotateDelay = rotateRetryMin
+		} else if w.rotateDelay *= 2; w.rotateDelay > rotateRetryMax {
+			w.rotateDelay = rotateRetryMax
+		}
+		w.rotateAt = time.Now().Add(w.rotateDelay)
+		w.handleError(fmt.Errorf("unable to rotate %s: %v", path, err))
+		return nil
+	}
+	w.rotateDelay = 0
+
+	if err := w.openFile(path); err != nil {
+		return err
+	}
+
+	w.compress(backup)
+	w.removeExpired()
+	return nil
+}
+
+// backupName returns an unused name for a backup of path, based on t.
+func backupName(path string, t time.Time) string {
+	name := path + "." + t.Format(backupTimeFmt)
+	backup := name
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			return backup
+		}
+		backup = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// isOldLogFile returns true if name is a backup of one of w's log
+// files, or, for a time-based LogWriter, the log file of another time
+// period.
+func (w *LogWriter) isOldLogFile(name string) bool {
+	if w.compressor != nil {
+		name = strings.TrimSuffix(name, w.compressor.Ext())
+	}
+
+	primary := name
+	if loc := backupSuffix.FindStringIndex(name); loc != nil {
+		primary = name[:loc[0]]
+	} else if !w.useTimeFmt {
+		return false
+	}
+
+	if !w.useTimeFmt {
+		return primary == w.name
+	}
+	_, err := time.ParseInLocation(w.name, primary, time.Local)
+	return err == nil
+}
+
+// removeExpired applies the WithMaxBackups and WithMaxAge retention
+// policy, deleting old log files.  The current log file is never
+// deleted.  Errors are ignored, the files will be reconsidered the
+// next time the LogWriter switches files.  The caller must hold w.mu.
+func (w *LogWriter) removeExpired() {
+	if w.maxBackups == 0 && w.maxAge == 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+
+	var current string
+	if w.fh != nil {
+		current = filepath.Base(w.fh.Name())
+	}
+
+	var old []os.FileInfo
+	for _, e := range entries {
+		if e.Name() == current || !e.Type().IsRegular() || !w.isOldLogFile(e.Name()) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			old = append(old, fi)
+		}
+	}
+
+	// newest first
+	sort.Slice(old, func(i, j int) bool {
+		return old[i].ModTime().After(old[j].ModTime())
+	})
+
+	cutoff := time.Now().Add(-w.maxAge)
+	for i, fi := range old {
+		if (w.maxBackups > 0 && i >= w.maxBackups) || (w.maxAge > 0 && fi.ModTime().Before(cutoff)) {
+			os.Remove(filepath.Join(w.dir, fi.Name()))
+		}
+	}
+}
@@ -117,8 +117,18 @@ func (mlog *MemLog) Close() {
 
 // ListenerFn is used to register the MemLog with the trace framework.
 func (mlog *MemLog) ListenerFn(t time.Time, path string, priority Priority, format string, args ...interface{}) {
+	mlog.push(priority, mlog.fmtFn(t, path, priority, format, args...))
+}
+
+// Listen implements EventListener, storing e formatted by the MemLog FormatterFn.
+func (mlog *MemLog) Listen(e *Event) {
+	mlog.push(e.Priority, formatEvent(mlog.fmtFn, e))
+}
+
+// push queues msg to be added to the MemLog, discarding it if the
+// queue is full.
+func (mlog *MemLog) push(priority Priority, msg string) {
 	mlog.wg.Add(1)
-	msg := mlog.fmtFn(t, path, priority, format, args...)
 	select {
 	case mlog.queue <- logEvent{priority: priority, msg: msg}:
 	default:
@@ -126,6 +136,22 @@ func (mlog *MemLog) ListenerFn(t time.Time, path string, priority Priority, form
 	}
 }
 
+// Register installs mlog in r for paths matched by pattern, accepting
+// only the Priority levels in priorities.  Levels that were not
+// defined in the MemLog limits are removed from priorities, since
+// their messages would be discarded.  If r is nil then DefaultRegistry
+// is used.
+func (mlog *MemLog) Register(r *Registry, pattern Pattern, priorities Priorities) listenerHandle {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	var limited Priorities
+	for priority := range mlog.limits {
+		limited |= Only(priority)
+	}
+	return r.RegisterListener(pattern, priorities&limited, mlog)
+}
+
 // run reads log messages from queue, adding them to the appropriate
 // priorityLog.  If limits have not been specified for a Priority,
 // the nessage will be discarded.
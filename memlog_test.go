@@ -456,6 +456,27 @@ func TestMemLogListenerFn(t *testing.T) {
 	}
 }
 
+func TestMemLogRegister(t *testing.T) {
+	limits := MemLogLimits{
+		Warn:  DefaultMemLogLimit,
+		Error: DefaultMemLogLimit,
+	}
+	mlog, err := NewMemLog(limits, 10, DefaultFormatterFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mlog.Close()
+
+	r := NewRegistry(nil)
+	handle := mlog.Register(r, Prefix("memlog"), Range(Info, Warn))
+	defer handle.Remove()
+
+	info := r.Listeners()
+	if len(info) != 1 || info[0].Priorities != Only(Warn) {
+		t.Errorf("expected listener restricted to [Warn], got %+v", info)
+	}
+}
+
 func BenchmarkMemLogListenerFn(b *testing.B) {
 	mlog, err := NewMemLog(DefaultMemLogLimits, 1000, DefaultFormatterFn)
 	if err != nil {
@@ -0,0 +1,190 @@
+package trace
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern selects the trace paths a listener is interested in.
+type Pattern interface {
+	// Match returns true if the listener should receive messages
+	// for path.
+	Match(path string) bool
+	// String returns a description of the pattern.
+	String() string
+}
+
+// prefixPattern matches a path equal to the prefix, or a path that
+// starts with the prefix followed by a '/'.  An empty prefix matches
+// every path.
+type prefixPattern string
+
+// Prefix returns a Pattern matching paths equal to prefix or below
+// it, e.g., the prefix "github.com/acme" matches "github.com/acme"
+// and "github.com/acme/db", but not "github.com/acmecorp".  The empty
+// prefix matches every path.  This is the matching used by Register.
+func Prefix(prefix string) Pattern {
+	return prefixPattern(prefix)
+}
+
+func (p prefixPattern) Match(path string) bool {
+	return matchPrefix(string(p), path)
+}
+
+func (p prefixPattern) String() string {
+	return string(p)
+}
+
+// matchPrefix implements the prefix matching of prefixPattern.
+func matchPrefix(prefix, path string) bool {
+	n := len(prefix)
+	if n == 0 {
+		return true
+	}
+	if !(len(path) >= n && path[0:n] == prefix) {
+		return false
+	}
+	if len(path) > n && path[n] != '/' {
+		return false
+	}
+	return true
+}
+
+// globPattern matches paths against a list of '/' separated segments.
+type globPattern struct {
+	glob     string
+	segments []string
+}
+
+// Glob returns a Pattern matching the whole of a path against glob.
+// The glob is split into '/' separated segments, each of which is
+// matched against the corresponding path segment using the syntax
+// of path.Match, so "*" matches any sequence of characters within a
+// single segment.  A segment consisting of "**" matches zero or more
+// path segments, e.g., "github.com/acme/*/db" matches
+// "github.com/acme/x/db", and "**/http" matches "http" and
+// "github.com/acme/http".  To also match the paths below a glob
+// append "/**".
+func Glob(glob string) (Pattern, error) {
+	segments := strings.Split(glob, "/")
+	for _, s := range segments {
+		if s == "**" {
+			continue
+		}
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+	}
+	return &globPattern{glob: glob, segments: segments}, nil
+}
+
+func (g *globPattern) Match(p string) bool {
+	return matchSegments(g.segments, strings.Split(p, "/"))
+}
+
+func (g *globPattern) String() string {
+	return g.glob
+}
+
+// matchSegments returns true if the glob segments match every
+// segment of the path.
+func matchSegments(glob, p []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			// collapse runs of "**"
+			for len(glob) > 0 && glob[0] == "**" {
+				glob = glob[1:]
+			}
+			if len(glob) == 0 {
+				return true
+			}
+			for i := 0; i <= len(p); i++ {
+				if matchSegments(glob, p[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(p) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], p[0]); !ok {
+			return false
+		}
+		glob, p = glob[1:], p[1:]
+	}
+	return len(p) == 0
+}
+
+// regexpPattern matches paths against a regular expression.
+type regexpPattern struct {
+	re *regexp.Regexp
+}
+
+// Regexp returns a Pattern matching any path for which re.MatchString
+// returns true.  Anchor the expression to match the whole path.  It
+// panics if re is nil.
+func Regexp(re *regexp.Regexp) Pattern {
+	if re == nil {
+		panic("trace: Regexp called with a nil *regexp.Regexp")
+	}
+	return &regexpPattern{re: re}
+}
+
+func (r *regexpPattern) Match(path string) bool {
+	return r.re.MatchString(path)
+}
+
+func (r *regexpPattern) String() string {
+	return "/" + r.re.String() + "/"
+}
+
+// excludePattern matches paths matched by include but not by any of
+// exclude.
+type excludePattern struct {
+	include Pattern
+	exclude []Pattern
+}
+
+// Exclude returns a Pattern that matches the paths matched by include
+// unless they are also matched by one of exclude, e.g.,
+//
+//	Exclude(Prefix("github.com/acme"), Prefix("github.com/acme/noisy"))
+//
+// matches everything under github.com/acme except github.com/acme/noisy.
+// It panics if any of the patterns is nil.
+func Exclude(include Pattern, exclude ...Pattern) Pattern {
+	if include == nil {
+		panic("trace: Exclude called with a nil include Pattern")
+	}
+	for _, p := range exclude {
+		if p == nil {
+			panic("trace: Exclude called with a nil exclude Pattern")
+		}
+	}
+	return &excludePattern{include: include, exclude: exclude}
+}
+
+func (e *excludePattern) Match(path string) bool {
+	if !e.include.Match(path) {
+		return false
+	}
+	for _, x := range e.exclude {
+		if x.Match(path) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *excludePattern) String() string {
+	var b strings.Builder
+	b.WriteString(e.include.String())
+	for _, x := range e.exclude {
+		b.WriteString(" !")
+		b.WriteString(x.String())
+	}
+	return b.String()
+}
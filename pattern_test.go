@@ -0,0 +1,149 @@
+package trace
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+type patternTest struct {
+	path     string
+	expected bool
+}
+
+func mustGlob(t *testing.T, glob string) Pattern {
+	p, err := Glob(glob)
+	if err != nil {
+		t.Fatalf("Glob(%q): %v", glob, err)
+	}
+	return p
+}
+
+func testPattern(t *testing.T, p Pattern, tests []patternTest) {
+	for _, v := range tests {
+		if actual := p.Match(v.path); actual != v.expected {
+			t.Errorf("%s: Match(%q) expected %v got %v", p, v.path, v.expected, actual)
+		}
+	}
+}
+
+func TestPrefixPattern(t *testing.T) {
+	testPattern(t, Prefix("trace"), []patternTest{
+		{"trace", true},
+		{"trace/a", true},
+		{"tracea", false},
+		{"tra", false},
+		{"", false},
+	})
+	testPattern(t, Prefix(""), []patternTest{
+		{"", true},
+		{"trace", true},
+	})
+}
+
+func TestGlobPattern(t *testing.T) {
+	testPattern(t, mustGlob(t, "github.com/acme/*/db"), []patternTest{
+		{"github.com/acme/x/db", true},
+		{"github.com/acme/yy/db", true},
+		{"github.com/acme/db", false},
+		{"github.com/acme/x/y/db", false},
+		{"github.com/acme/x/db/z", false},
+		{"", false},
+	})
+	testPattern(t, mustGlob(t, "**/http"), []patternTest{
+		{"http", true},
+		{"github.com/acme/http", true},
+		{"github.com/acme/http/client", false},
+		{"github.com/acme/https", false},
+		{"", false},
+	})
+	testPattern(t, mustGlob(t, "github.com/acme/**"), []patternTest{
+		{"github.com/acme", true},
+		{"github.com/acme/a/b/c", true},
+		{"github.com/acmecorp", false},
+	})
+	testPattern(t, mustGlob(t, "**"), []patternTest{
+		{"", true},
+		{"a/b", true},
+	})
+	testPattern(t, mustGlob(t, ""), []patternTest{
+		{"", true},
+		{"a", false},
+	})
+
+	if _, err := Glob("github.com/[acme"); err == nil {
+		t.Errorf("expected an error for a malformed glob")
+	}
+}
+
+func TestRegexpPattern(t *testing.T) {
+	testPattern(t, Regexp(regexp.MustCompile(`^github\.com/acme/(db|http)$`)), []patternTest{
+		{"github.com/acme/db", true},
+		{"github.com/acme/http", true},
+		{"github.com/acme/rpc", false},
+		{"", false},
+	})
+}
+
+func TestExcludePattern(t *testing.T) {
+	testPattern(t, Exclude(Prefix("github.com/acme"), Prefix("github.com/acme/noisy")), []patternTest{
+		{"github.com/acme", true},
+		{"github.com/acme/db", true},
+		{"github.com/acme/noisy", false},
+		{"github.com/acme/noisy/a", false},
+		{"github.com/acme/noisyneighbor", true},
+		{"github.com/other", false},
+	})
+}
+
+func TestRegisterPattern(t *testing.T) {
+	r := NewRegistry(nil)
+
+	called := 0
+	handle := r.RegisterPattern(mustGlob(t, "**/http"), Info, func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+		called++
+	})
+	defer handle.Remove()
+
+	for _, path := range []string{"github.com/acme/http", "github.com/acme/db", ""} {
+		if m, ok := r.M(path, Info); ok {
+			T(m, "hello")
+		}
+	}
+
+	if called != 1 {
+		t.Errorf("expected listener to be called once, got %d", called)
+	}
+
+	info := r.Listeners()
+	if len(info) != 1 || info[0].Pattern != "**/http" || info[0].Prefix != "" {
+		t.Errorf("unexpected listener info: %+v", info)
+	}
+}
+
+func TestNilPattern(t *testing.T) {
+	r := NewRegistry(nil)
+
+	for name, fn := range map[string]func(){
+		"RegisterPattern": func() { r.RegisterPattern(nil, Info, discardListenerFn) },
+		"Regexp":          func() { Regexp(nil) },
+		"Exclude":         func() { Exclude(nil) },
+		"Exclude exclude": func() { Exclude(Prefix("a"), nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic for a nil pattern", name)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	if len(r.Listeners()) != 0 {
+		t.Errorf("expected no listener to be installed, got %+v", r.Listeners())
+	}
+	if _, ok := r.M("anything", Info); ok {
+		t.Errorf("expected no match after rejecting a nil pattern")
+	}
+}
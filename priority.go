@@ -74,3 +74,76 @@ func (p Priority) String() string {
 		return fmt.Sprintf("%d", int(p))
 	}
 }
+
+// Priorities is a set of Priority levels a listener accepts.
+type Priorities uint8
+
+// AllPriorities is the set of every Priority level.
+const AllPriorities = Priorities(1<<(None+1) - 1)
+
+// AtLeast returns the set of Priority levels >= min, which is the set
+// accepted by a listener installed with Register.
+func AtLeast(min Priority) Priorities {
+	return Range(min, None)
+}
+
+// Range returns the set of Priority levels >= min and <= max.  If
+// max < min the set will be empty.
+func Range(min, max Priority) Priorities {
+	var s Priorities
+	for p := min; p <= max && p <= None; p++ {
+		s |= 1 << p
+	}
+	return s
+}
+
+// Only returns the set containing just the specified Priority levels.
+func Only(priorities ...Priority) Priorities {
+	var s Priorities
+	for _, p := range priorities {
+		if p <= None {
+			s |= 1 << p
+		}
+	}
+	return s
+}
+
+// Has returns true if p is a member of the set.
+func (s Priorities) Has(p Priority) bool {
+	return p <= None && s&(1<<p) != 0
+}
+
+// Min returns the lowest Priority in the set, or None if the set is
+// empty.
+func (s Priorities) Min() Priority {
+	for p := Trace; p < None; p++ {
+		if s.Has(p) {
+			return p
+		}
+	}
+	return None
+}
+
+// Max returns the highest Priority in the set, or None if the set is
+// empty.
+func (s Priorities) Max() Priority {
+	for p := None; p > Trace; p-- {
+		if s.Has(p) {
+			return p
+		}
+	}
+	if s.Has(Trace) {
+		return Trace
+	}
+	return None
+}
+
+func (s Priorities) String() string {
+	var names []string
+	for p := Trace; p <= None; p++ {
+		if s.Has(p) {
+			names = append(names, p.String())
+		}
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
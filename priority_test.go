@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"testing"
+)
+
+func TestPriorities(t *testing.T) {
+	tests := []struct {
+		set      Priorities
+		expected []Priority
+		min, max Priority
+	}{
+		{AtLeast(Warn), []Priority{Warn, Error, None}, Warn, None},
+		{Range(Trace, Debug), []Priority{Trace, Debug}, Trace, Debug},
+		{Range(Warn, Info), nil, None, None},
+		{Only(Info, Error), []Priority{Info, Error}, Info, Error},
+		{Only(Trace), []Priority{Trace}, Trace, Trace},
+		{AllPriorities, []Priority{Trace, Debug, Info, Warn, Error, None}, Trace, None},
+	}
+
+	for i, v := range tests {
+		for p := Trace; p <= None; p++ {
+			expected := false
+			for _, e := range v.expected {
+				if e == p {
+					expected = true
+				}
+			}
+			if v.set.Has(p) != expected {
+				t.Errorf("%d: %s.Has(%s) expected %v", i, v.set, p, expected)
+			}
+		}
+		if v.set.Min() != v.min {
+			t.Errorf("%d: %s.Min() expected %s got %s", i, v.set, v.min, v.set.Min())
+		}
+		if v.set.Max() != v.max {
+			t.Errorf("%d: %s.Max() expected %s got %s", i, v.set, v.max, v.set.Max())
+		}
+	}
+
+	if s := Range(Debug, Warn).String(); s != "[Debug,Info,Warn]" {
+		t.Errorf("expected [Debug,Info,Warn] got %s", s)
+	}
+}
@@ -0,0 +1,306 @@
+package trace
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds a set of listeners.  The package level functions
+// Register and M operate on DefaultRegistry, independent registries
+// may be created with NewRegistry to isolate a subsystem or a test
+// from the rest of the program.
+//
+// A Registry may be chained to a parent, in which case M will also
+// return the parent's listeners that match.
+type Registry struct {
+	// parent is an optional Registry to forward matches to
+	parent *Registry
+	// mu serializes changes to the registry, readers do not need to
+	// acquire it.
+	mu sync.Mutex
+	// current holds an immutable snapshot of the installed listeners.
+	// Register and Remove publish a new snapshot, M reads the current
+	// snapshot without locking.
+	current atomic.Pointer[snapshot]
+	// lastId holds the most recently assigned listener id, it is
+	// guarded by mu.
+	lastId uint64
+}
+
+// DefaultRegistry is the Registry used by the package level functions.
+var DefaultRegistry = NewRegistry(nil)
+
+// NewRegistry returns a new empty Registry.  If parent is not nil
+// then M will forward to it, returning the listeners of both
+// registries.
+func NewRegistry(parent *Registry) *Registry {
+	return &Registry{parent: parent}
+}
+
+// ListenerInfo describes a listener installed in a Registry.
+type ListenerInfo struct {
+	// Id is the unique id assigned to the listener by Register
+	Id uint64
+	// Prefix is the path prefix the listener was registered with, it
+	// is empty if the listener was registered with another Pattern
+	Prefix string
+	// Pattern describes the paths the listener was registered with
+	Pattern string
+	// Min is the minimum Priority accepted by the listener
+	Min Priority
+	// Max is the maximum Priority accepted by the listener
+	Max Priority
+	// Priorities is the set of Priority levels accepted by the listener
+	Priorities Priorities
+	// Sink describes the function or type receiving events
+	Sink string
+}
+
+// snapshot is an immutable list of listeners, it must not be modified
+// once it has been published to a Registry.  The generation is bumped
+// each time a new snapshot is published.
+type snapshot struct {
+	generation uint64
+	listeners  []*listener
+}
+
+// emptySnapshot is returned by load before any listener has been
+// registered.
+var emptySnapshot = &snapshot{}
+
+// load returns the current registry snapshot.
+func (r *Registry) load() *snapshot {
+	if s := r.current.Load(); s != nil {
+		return s
+	}
+	return emptySnapshot
+}
+
+// publish stores a new registry snapshot, the caller must hold r.mu.
+func (r *Registry) publish(listeners []*listener) {
+	r.current.Store(&snapshot{
+		generation: r.load().generation + 1,
+		listeners:  listeners,
+	})
+}
+
+// generation returns a number that changes whenever a listener is
+// registered or removed from r or any of its ancestors.
+func (r *Registry) generation() uint64 {
+	var n uint64
+	for ; r != nil; r = r.parent {
+		n += r.load().generation
+	}
+	return n
+}
+
+// Register installs a new listener for paths equal to or below prefix
+func (r *Registry) Register(prefix string, min Priority, fn ListenerFn) listenerHandle {
+	return r.RegisterPattern(Prefix(prefix), min, fn)
+}
+
+// RegisterPattern installs a new listener for paths matched by pattern
+func (r *Registry) RegisterPattern(pattern Pattern, min Priority, fn ListenerFn) listenerHandle {
+	return r.RegisterPriorities(pattern, AtLeast(min), fn)
+}
+
+// RegisterPriorities installs a new listener for paths matched by
+// pattern, accepting only the Priority levels in priorities, e.g.,
+// Range(Trace, Debug) or Only(Warn, Error).
+func (r *Registry) RegisterPriorities(pattern Pattern, priorities Priorities, fn ListenerFn) listenerHandle {
+	return r.RegisterListener(pattern, priorities, fn)
+}
+
+// RegisterListener installs a new EventListener for paths matched by
+// pattern, accepting only the Priority levels in priorities.  It
+// panics if pattern is nil; use Prefix("") to match every path.
+func (r *Registry) RegisterListener(pattern Pattern, priorities Priorities, el EventListener) listenerHandle {
+	handles := r.replace(nil, []listenerSpec{{pattern, priorities, el}})
+	return handles[0]
+}
+
+// listenerSpec holds the arguments to RegisterListener, for use with
+// replace.
+type listenerSpec struct {
+	pattern    Pattern
+	priorities Priorities
+	el         EventListener
+}
+
+// replace removes the listeners with the specified ids and installs
+// new listeners for each of add, publishing a single snapshot so that
+// M observes either all or none of the changes.
+func (r *Registry) replace(remove []uint64, add []listenerSpec) []listenerHandle {
+	for _, spec := range add {
+		if spec.pattern == nil {
+			panic("trace: listener registered with a nil Pattern")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur := r.load().listeners
+	next := make([]*listener, 0, len(cur)+len(add))
+	for _, l := range cur {
+		removed := false
+		for _, id := range remove {
+			if l.id == id {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			next = append(next, l)
+		}
+	}
+
+	handles := make([]listenerHandle, len(add))
+	for i, spec := range add {
+		r.lastId++
+		next = append(next, newListener(r.lastId, spec.pattern, spec.priorities, spec.el))
+		handles[i] = listenerHandle{registry: r, id: r.lastId}
+	}
+
+	r.publish(next)
+
+	return handles
+}
+
+// update replaces the pattern and priorities of the listener with the
+// specified id, keeping its id and position, returning true if it
+// was found.
+func (r *Registry) update(id uint64, pattern Pattern, priorities Priorities) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur := r.load().listeners
+	for i, l := range cur {
+		if l.id == id {
+			next := make([]*listener, len(cur))
+			copy(next, cur)
+			next[i] = newListener(id, pattern, priorities, l.el)
+			r.publish(next)
+			return true
+		}
+	}
+	return false
+}
+
+// pattern returns the Pattern and Priorities of the listener with
+// the specified id, or false if it was not found.
+func (r *Registry) pattern(id uint64) (Pattern, Priorities, bool) {
+	for _, l := range r.load().listeners {
+		if l.id == id {
+			return l.Pattern(), l.priorities, true
+		}
+	}
+	return nil, 0, false
+}
+
+// remove uninstalls the listener with the specified id, returning
+// true if it was found.
+func (r *Registry) remove(id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur := r.load().listeners
+	for i, l := range cur {
+		if l.id == id {
+			next := make([]*listener, 0, len(cur)-1)
+			next = append(next, cur[0:i]...)
+			next = append(next, cur[i+1:]...)
+			r.publish(next)
+			return true
+		}
+	}
+	return false
+}
+
+// Listeners returns a description of each listener installed in r,
+// in the order they were registered.  Listeners of a parent Registry
+// are not included.
+func (r *Registry) Listeners() []ListenerInfo {
+	cur := r.load().listeners
+	info := make([]ListenerInfo, len(cur))
+	for i, l := range cur {
+		info[i] = ListenerInfo{
+			Id:         l.id,
+			Prefix:     l.prefix,
+			Pattern:    l.String(),
+			Min:        l.priorities.Min(),
+			Max:        l.priorities.Max(),
+			Priorities: l.priorities,
+			Sink:       l.sink(),
+		}
+	}
+	return info
+}
+
+// Listener returns a description of the listener with the specified
+// id, or false if it is not installed in r.
+func (r *Registry) Listener(id uint64) (ListenerInfo, bool) {
+	for _, info := range r.Listeners() {
+		if info.Id == id {
+			return info, true
+		}
+	}
+	return ListenerInfo{}, false
+}
+
+// Parent returns the Registry r forwards to, or nil.
+func (r *Registry) Parent() *Registry {
+	return r.parent
+}
+
+// M searches r and its ancestors for any listener matching the
+// specified path and priority level.  When ok is true the returned
+// match should be passed to T.
+func (r *Registry) M(path string, priority Priority) (match []listenerMatch, ok bool) {
+	for ; r != nil; r = r.parent {
+		match = r.load().match(path, priority, match)
+	}
+	return match, len(match) > 0
+}
+
+// T logs the format and args to each listener function in match
+func (r *Registry) T(match []listenerMatch, format string, args ...interface{}) {
+	T(match, format, args...)
+}
+
+// Tracer returns a new Tracer for path that uses the listeners
+// installed in r.
+func (r *Registry) Tracer(path string) *Tracer {
+	return &Tracer{registry: r, path: path}
+}
+
+// match appends to match any listener in the snapshot matching the
+// specified path and priority level.
+func (s *snapshot) match(path string, priority Priority, match []listenerMatch) []listenerMatch {
+	npath := len(path)
+
+	for i, l := range s.listeners {
+		if !l.priorities.Has(priority) {
+			continue
+		}
+		if l.pattern != nil {
+			if !l.pattern.Match(path) {
+				continue
+			}
+		} else if n := len(l.prefix); n > 0 {
+			if !(npath >= n && path[0:n] == l.prefix) {
+				continue
+			}
+			if npath > n && path[n] != '/' {
+				continue
+			}
+		}
+		// defer allocating match until we know it will be needed
+		if match == nil {
+			match = make([]listenerMatch, 0, len(s.listeners)-i)
+		}
+		match = append(match, newListenerMatch(path, priority, l))
+	}
+
+	return match
+}
@@ -0,0 +1,146 @@
+package trace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistryIsolation(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry(nil)
+
+	called := 0
+	handle := r.Register("isolated", Info, func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+		called++
+	})
+	defer handle.Remove()
+
+	if _, ok := M("isolated", Info); ok {
+		t.Errorf("listener registered in a new Registry matched in DefaultRegistry")
+	}
+
+	if m, ok := r.M("isolated", Info); ok {
+		r.T(m, "hello")
+	} else {
+		t.Errorf("expected listener to match in its own Registry")
+	}
+
+	if called != 1 {
+		t.Errorf("expected listener to be called once, got %d", called)
+	}
+}
+
+func TestRegistryChain(t *testing.T) {
+	t.Parallel()
+
+	parent := NewRegistry(nil)
+	child := NewRegistry(parent)
+
+	if child.Parent() != parent {
+		t.Errorf("expected child.Parent() to return parent")
+	}
+
+	var seen []string
+	listenerFn := func(name string) ListenerFn {
+		return func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+			seen = append(seen, name)
+		}
+	}
+
+	ph := parent.Register("chain", Warn, listenerFn("parent"))
+	ch := child.Register("chain", Info, listenerFn("child"))
+	defer ch.Remove()
+
+	if m, ok := child.M("chain/a", Warn); ok {
+		T(m, "hello")
+	}
+	if len(seen) != 2 || seen[0] != "child" || seen[1] != "parent" {
+		t.Errorf("expected [child parent], got %v", seen)
+	}
+
+	seen = nil
+	if m, ok := parent.M("chain/a", Warn); ok {
+		T(m, "hello")
+	}
+	if len(seen) != 1 || seen[0] != "parent" {
+		t.Errorf("expected parent to not forward to child, got %v", seen)
+	}
+
+	tr := child.Tracer("chain")
+	if !tr.Enabled(Warn) {
+		t.Errorf("expected Warn to be enabled via parent")
+	}
+
+	ph.Remove()
+
+	if !tr.Enabled(Warn) {
+		t.Errorf("expected Warn to remain enabled via child")
+	}
+	if m, _ := child.M("chain", Warn); len(m) != 1 {
+		t.Errorf("expected 1 match after removing the parent listener, got %d", len(m))
+	}
+}
+
+func TestRegistryListeners(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry(nil)
+	a := r.Register("a", Info, discardListenerFn)
+	b := r.Register("b", Error, discardListenerFn)
+	defer b.Remove()
+
+	info := r.Listeners()
+	if len(info) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(info))
+	}
+	if info[0].Id != a.Id() || info[0].Prefix != "a" || info[0].Min != Info {
+		t.Errorf("unexpected listener info: %+v", info[0])
+	}
+	if info[1].Id != b.Id() || info[1].Prefix != "b" || info[1].Min != Error {
+		t.Errorf("unexpected listener info: %+v", info[1])
+	}
+
+	a.Remove()
+
+	info = r.Listeners()
+	if len(info) != 1 || info[0].Id != b.Id() {
+		t.Errorf("expected only listener b to remain, got %+v", info)
+	}
+}
+
+func TestRegistryPriorities(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry(nil)
+
+	seen := make(map[string][]Priority)
+	listenerFn := func(name string) ListenerFn {
+		return func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+			seen[name] = append(seen[name], n)
+		}
+	}
+
+	low := r.RegisterPriorities(Prefix(""), Range(Trace, Debug), listenerFn("low"))
+	defer low.Remove()
+	high := r.RegisterPriorities(Prefix(""), Only(Warn, Error), listenerFn("high"))
+	defer high.Remove()
+
+	for p := Trace; p < None; p++ {
+		if m, ok := r.M("priorities", p); ok {
+			T(m, "hello")
+		}
+	}
+
+	if len(seen["low"]) != 2 || seen["low"][0] != Trace || seen["low"][1] != Debug {
+		t.Errorf("expected low listener to see [Trace Debug], got %v", seen["low"])
+	}
+	if len(seen["high"]) != 2 || seen["high"][0] != Warn || seen["high"][1] != Error {
+		t.Errorf("expected high listener to see [Warn Error], got %v", seen["high"])
+	}
+
+	info := r.Listeners()
+	if info[0].Min != Trace || info[0].Max != Debug || info[0].Priorities != Range(Trace, Debug) {
+		t.Errorf("unexpected listener info: %+v", info[0])
+	}
+}
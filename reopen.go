@@ -0,0 +1,158 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// logWriters tracks each open LogWriter that may be reopened by path,
+// for use by ReopenAll.
+var logWriters = struct {
+	sync.Mutex
+	m map[*LogWriter]struct{}
+}{m: make(map[*LogWriter]struct{})}
+
+// track adds w to logWriters, unless w writes to a file that is never
+// reopened.
+func (w *LogWriter) track() {
+	if w.fixed {
+		return
+	}
+	logWriters.Lock()
+	logWriters.m[w] = struct{}{}
+	logWriters.Unlock()
+}
+
+// untrack removes w from logWriters.
+func (w *LogWriter) untrack() {
+	logWriters.Lock()
+	delete(logWriters.m, w)
+	logWriters.Unlock()
+}
+
+// WithManualReopen returns a LogWriterOption that disables the
+// periodic check for a renamed, removed, or truncated log file, see
+// WithCheckInterval.  The log file is then only reopened when Reopen
+// is called, for example by ReopenOnSignal after logrotate has moved
+// the file aside.
+func WithManualReopen() LogWriterOption {
+	return func(w *LogWriter) error {
+		w.checkInterval = -1
+		return nil
+	}
+}
+
+// Reopen flushes and closes the current log file, and opens it again
+// by name.  It is intended to be called once an external program such
+// as logrotate has renamed the log file, so that later writes go to a
+// new file at the original path.  Any retry backoff following a failed
+// write is reset.  Reopen does nothing if the LogWriter was created
+// with NewFileLogWriter for a file that is not a regular file, or if
+// it has been closed.
+func (w *LogWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fixed || w.closed {
+		return nil
+	}
+
+	var err error
+	if w.fh != nil {
+		err = w.closeFile()
+		w.fh = nil
+		w.stat = nil
+	}
+	w.retryAt = time.Time{}
+	w.retryDelay = 0
+
+	if perr := w.checkPath(); perr != nil {
+		err = perr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to reopen log file %s: %v", w.path, err)
+	}
+	return nil
+}
+
+// ReopenAll calls Reopen on every LogWriter that has not been closed.
+// Errors are passed to the error handler of the LogWriter, see
+// WithErrorHandler, and the first error is returned.
+func ReopenAll() error {
+	logWriters.Lock()
+	writers := make([]*LogWriter, 0, len(logWriters.m))
+	for w := range logWriters.m {
+		writers = append(writers, w)
+	}
+	logWriters.Unlock()
+
+	var first error
+	for _, w := range writers {
+		if err := w.Reopen(); err != nil {
+			w.reportError(err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// ReopenOnSignal calls ReopenAll each time the process receives one of
+// sigs, until the returned stop function is called.  If no signals are
+// specified SIGHUP and SIGUSR1 are used, on platforms that have them.
+func ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = reopenSignals
+	}
+
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-c:
+				ReopenAll()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// checkFile checks whether the log file at path has been renamed or
+// removed, in which case it is reopened, or truncated in place, as by
+// logrotate's copytruncate, in which case the tracked size is reset.
+// The caller must hold w.mu.
+func (w *LogWriter) checkFile(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil || !os.SameFile(w.stat, stat) {
+		return w.openFile(path)
+	}
+
+	written := w.size
+	if w.buf != nil {
+		written -= int64(w.buf.Buffered())
+	}
+	if stat.Size() < written {
+		w.stat = stat
+		w.size = stat.Size()
+		if w.buf != nil {
+			w.size += int64(w.buf.Buffered())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package trace
+
+import "os"
+
+// reopenSignals are the signals used by ReopenOnSignal by default,
+// there are none on this platform.
+var reopenSignals []os.Signal
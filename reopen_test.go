@@ -0,0 +1,175 @@
+package trace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogWriterReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithManualReopen())
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	path := filepath.Join(dir, "test.log")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("before\n"))
+	if err := w.Reopen(); err != nil {
+		t.Fatalf("Reopen error: %v", err)
+	}
+	w.Write([]byte("after\n"))
+
+	for name, expected := range map[string]string{"test.log.1": "before\n", "test.log": "after\n"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, string(b))
+		}
+	}
+}
+
+func TestReopenAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	names := []string{"a.log", "b.log"}
+	for _, name := range names {
+		w, err := NewLogWriter(dir, name, 0644, DefaultFormatterFn, WithManualReopen())
+		if err != nil {
+			t.Fatalf("unable to open new LogWriter: %v", err)
+		}
+
+		defer w.Close()
+
+		path := filepath.Join(dir, name)
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ReopenAll(); err != nil {
+		t.Fatalf("ReopenAll error: %v", err)
+	}
+
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be reopened: %v", name, err)
+		}
+	}
+}
+
+func TestLogWriterCopyTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithCheckInterval(0), WithMaxSize(20))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	path := filepath.Join(dir, "test.log")
+	w.Write([]byte("first message\n"))
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("second message\n"))
+
+	// the truncated file has room for the second message, so it must
+	// not have been rotated
+	files, _ := filepath.Glob(filepath.Join(dir, "test.log.*"))
+	if len(files) != 0 {
+		t.Errorf("expected no backups after the file was truncated, got %v", files)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "second message\n" {
+		t.Errorf("expected %q, got %q", "second message\n", string(b))
+	}
+}
+
+func TestLogWriterReopenClosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn)
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	path := filepath.Join(dir, "test.log")
+	w.Close()
+	os.Remove(path)
+
+	if err := ReopenAll(); err != nil {
+		t.Fatalf("ReopenAll error: %v", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("expected a closed LogWriter not to be reopened")
+	}
+}
+
+func TestLogWriterOpenErrorNotTracked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	// a directory where the log file should be
+	if err := os.Mkdir(filepath.Join(dir, "test.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	logWriters.Lock()
+	tracked := len(logWriters.m)
+	logWriters.Unlock()
+
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn, WithBuffer(1024, time.Millisecond))
+	if err == nil || w != nil {
+		t.Fatalf("expected an error opening a directory as a log file, got %v %v", w, err)
+	}
+	if _, err := NewTimeLogWriter(dir, "test.log", 0644, DefaultFormatterFn); err == nil {
+		t.Fatalf("expected an error opening a directory as a log file")
+	}
+
+	logWriters.Lock()
+	defer logWriters.Unlock()
+	if len(logWriters.m) != tracked {
+		t.Errorf("expected a LogWriter that failed to open not to be tracked")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package trace
+
+import (
+	"os"
+	"syscall"
+)
+
+// reopenSignals are the signals used by ReopenOnSignal by default.
+var reopenSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
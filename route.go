@@ -0,0 +1,273 @@
+package trace
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteFn returns the name of the log file, relative to the log
+// directory, that an event for path and priority should be written
+// to.  The name may contain path separators to place the file in a
+// sub-directory.
+type RouteFn func(path string, priority Priority) string
+
+// DefaultRouteFn routes events to a file named after their priority,
+// in a sub-directory named after the last segment of their path, e.g.,
+// an Error event for "github.com/acme/db" is written to "db/error.log".
+// Events with an empty path are written to the "default" directory.
+func DefaultRouteFn(path string, priority Priority) string {
+	segment := path[strings.LastIndex(path, "/")+1:]
+	switch segment {
+	case "", ".", "..":
+		segment = "default"
+	}
+	segment = strings.Replace(segment, string(filepath.Separator), "_", -1)
+	return filepath.Join(segment, strings.ToLower(priority.String())+".log")
+}
+
+// DefaultMaxOpen is the number of log files a RoutingLogWriter keeps
+// open when NewRoutingLogWriter is passed a maxOpen < 1.
+var DefaultMaxOpen = 64
+
+// RoutingLogWriter writes each event to a LogWriter chosen by a
+// RouteFn, so that a single listener can split the events for many
+// paths into separate files.  At most maxOpen log files are kept
+// open, the least recently used file is closed in the background to
+// make room for another, and reopened if it is routed to again.
+type RoutingLogWriter struct {
+	dir     string
+	perm    os.FileMode
+	fmtFn   FormatterFn
+	routeFn RouteFn
+	opts    []LogWriterOption
+	maxOpen int
+
+	// mu guards writers, lru, closed, and the routedWriter refs, it
+	// is not held while writing to a LogWriter
+	mu sync.Mutex
+	// writers maps a file name returned by routeFn to its element in
+	// lru, the front of lru is the most recently used LogWriter
+	writers map[string]*list.Element
+	lru     *list.List
+	closed  bool
+	// active tracks the writes in progress, and closing the evicted
+	// LogWriters being closed in the background
+	active  sync.WaitGroup
+	closing sync.WaitGroup
+	// errFn is the error handler set by opts, if any
+	errFn func(err error)
+}
+
+// routedWriter is the value of each RoutingLogWriter lru element.
+// An evicted LogWriter is closed once refs, the number of writes in
+// progress, drops to zero.
+type routedWriter struct {
+	name    string
+	w       *LogWriter
+	refs    int
+	evicted bool
+}
+
+// NewRoutingLogWriter initializes a new RoutingLogWriter writing to
+// files below dir, as named by routeFn, which defaults to
+// DefaultRouteFn when nil.  The supplied perm, fmtFn and opts are used
+// to create the LogWriter for each file, see NewLogWriter.  Missing
+// directories are created with mode 0755, unless opts includes
+// WithMkdirAll.
+func NewRoutingLogWriter(dir string, routeFn RouteFn, maxOpen int, perm os.FileMode, fmtFn FormatterFn, opts ...LogWriterOption) (*RoutingLogWriter, error) {
+	if routeFn == nil {
+		routeFn = DefaultRouteFn
+	}
+	if maxOpen < 1 {
+		maxOpen = DefaultMaxOpen
+	}
+
+	rw := &RoutingLogWriter{
+		dir:     dir,
+		perm:    perm,
+		fmtFn:   fmtFn,
+		routeFn: routeFn,
+		opts:    append([]LogWriterOption{WithMkdirAll(0755)}, opts...),
+		maxOpen: maxOpen,
+		writers: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	// check the options and the log directory up front, rather than
+	// on the first event
+	probe := &LogWriter{dir: dir}
+	for _, opt := range rw.opts {
+		if err := opt(probe); err != nil {
+			return nil, err
+		}
+	}
+	if err := probe.checkDir(); err != nil {
+		return nil, err
+	}
+	rw.errFn = probe.errFn
+
+	return rw, nil
+}
+
+// ListenerFn provides a hook to register a RoutingLogWriter with the
+// trace framework, writing the event to the LogWriter chosen by the
+// RouteFn.  Errors opening or writing to a log file are passed to the
+// handler set with WithErrorHandler, if any.
+func (rw *RoutingLogWriter) ListenerFn(t time.Time, path string, priority Priority, format string, args ...interface{}) {
+	rw.route(path, priority, func(w *LogWriter) {
+		w.ListenerFn(t, path, priority, format, args...)
+	})
+}
+
+// Listen implements EventListener, passing e to the LogWriter chosen by the RouteFn.
+func (rw *RoutingLogWriter) Listen(e *Event) {
+	rw.route(e.Path, e.Priority, func(w *LogWriter) {
+		w.Listen(e)
+	})
+}
+
+// route calls fn with the LogWriter chosen by the RouteFn for path and
+// priority, which will not be closed until fn returns.
+func (rw *RoutingLogWriter) route(path string, priority Priority, fn func(w *LogWriter)) {
+	name := rw.routeFn(path, priority)
+
+	rw.mu.Lock()
+	r, err := rw.writer(name)
+	if err == nil {
+		r.refs++
+		rw.active.Add(1)
+	}
+	rw.mu.Unlock()
+
+	if err != nil {
+		if rw.errFn != nil {
+			rw.errFn(err)
+		}
+		return
+	}
+
+	fn(r.w)
+
+	rw.mu.Lock()
+	rw.release(r)
+	rw.mu.Unlock()
+	rw.active.Done()
+}
+
+// Register installs rw in r for paths matched by pattern, accepting
+// only the Priority levels in priorities.  If r is nil then
+// DefaultRegistry is used.
+func (rw *RoutingLogWriter) Register(r *Registry, pattern Pattern, priorities Priorities) listenerHandle {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return r.RegisterListener(pattern, priorities, rw)
+}
+
+// writer returns the routedWriter for the file name, opening it and
+// evicting the least recently used LogWriter if necessary.  The caller
+// must hold rw.mu.
+func (rw *RoutingLogWriter) writer(name string) (*routedWriter, error) {
+	if rw.closed {
+		return nil, fmt.Errorf("RoutingLogWriter is closed")
+	}
+
+	if e, ok := rw.writers[name]; ok {
+		rw.lru.MoveToFront(e)
+		return e.Value.(*routedWriter), nil
+	}
+
+	path := filepath.Join(rw.dir, name)
+	w, err := NewLogWriter(filepath.Dir(path), filepath.Base(path), rw.perm, rw.fmtFn, rw.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rw.lru.Len() >= rw.maxOpen {
+		rw.evict(rw.lru.Back())
+	}
+	r := &routedWriter{name: name, w: w}
+	rw.writers[name] = rw.lru.PushFront(r)
+
+	return r, nil
+}
+
+// evict removes e from rw, closing its LogWriter once it is no longer
+// being written to.  The caller must hold rw.mu.
+func (rw *RoutingLogWriter) evict(e *list.Element) {
+	r := rw.lru.Remove(e).(*routedWriter)
+	delete(rw.writers, r.name)
+	r.evicted = true
+	rw.closeUnused(r)
+}
+
+// release records that a write to r has finished.  The caller must
+// hold rw.mu.
+func (rw *RoutingLogWriter) release(r *routedWriter) {
+	r.refs--
+	rw.closeUnused(r)
+}
+
+// closeUnused closes the LogWriter held by r in the background, once
+// it has been evicted and is no longer being written to, so routing
+// other events never waits on closing a file or on its compression.
+// The caller must hold rw.mu.
+func (rw *RoutingLogWriter) closeUnused(r *routedWriter) {
+	if !r.evicted || r.refs > 0 {
+		return
+	}
+
+	rw.closing.Add(1)
+	go func() {
+		defer rw.closing.Done()
+		if err := r.w.Close(); err != nil && rw.errFn != nil {
+			rw.errFn(err)
+		}
+	}()
+}
+
+// Open returns the names of the log files currently open, from the
+// most to the least recently used.
+func (rw *RoutingLogWriter) Open() []string {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	names := make([]string, 0, rw.lru.Len())
+	for e := rw.lru.Front(); e != nil; e = e.Next() {
+		names = append(names, e.Value.(*routedWriter).name)
+	}
+	return names
+}
+
+// Close waits for any writes in progress, and closes each log file,
+// returning the first error encountered.  The RoutingLogWriter
+// discards events once closed.
+func (rw *RoutingLogWriter) Close() (err error) {
+	rw.mu.Lock()
+	rw.closed = true
+	rw.mu.Unlock()
+
+	rw.active.Wait()
+
+	rw.mu.Lock()
+	writers := make([]*LogWriter, 0, rw.lru.Len())
+	for rw.lru.Len() > 0 {
+		r := rw.lru.Remove(rw.lru.Front()).(*routedWriter)
+		delete(rw.writers, r.name)
+		writers = append(writers, r.w)
+	}
+	rw.mu.Unlock()
+
+	for _, w := range writers {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	rw.closing.Wait()
+	return err
+}
@@ -0,0 +1,223 @@
+package trace
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDefaultRouteFn(t *testing.T) {
+	for _, v := range []struct {
+		path     string
+		priority Priority
+		expected string
+	}{
+		{"github.com/acme/db", Error, filepath.Join("db", "error.log")},
+		{"http", Info, filepath.Join("http", "info.log")},
+		{"", Debug, filepath.Join("default", "debug.log")},
+		{"github.com/acme/", Warn, filepath.Join("default", "warn.log")},
+		{"github.com/..", Warn, filepath.Join("default", "warn.log")},
+	} {
+		if actual := DefaultRouteFn(v.path, v.priority); actual != v.expected {
+			t.Errorf("%q %s: expected %s, got %s", v.path, v.priority, v.expected, actual)
+		}
+	}
+}
+
+func TestRoutingLogWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fmtFn := func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+		return format
+	}
+
+	rw, err := NewRoutingLogWriter(dir, nil, 2, 0644, fmtFn)
+	if err != nil {
+		t.Fatalf("unable to open new RoutingLogWriter: %v", err)
+	}
+
+	now := time.Now()
+	rw.ListenerFn(now, "acme/db", Error, "db error")
+	rw.ListenerFn(now, "acme/http", Info, "http info")
+	rw.ListenerFn(now, "acme/db", Info, "db info")
+
+	expected := []string{filepath.Join("db", "info.log"), filepath.Join("http", "info.log")}
+	if open := rw.Open(); !reflect.DeepEqual(open, expected) {
+		t.Errorf("expected open files %v, got %v", expected, open)
+	}
+
+	// db/error.log was evicted, it must be reopened and appended to
+	rw.ListenerFn(now, "acme/db", Error, "db error again")
+
+	if err := rw.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	rw.ListenerFn(now, "acme/db", Error, "after close")
+
+	for name, content := range map[string]string{
+		"db/error.log":  "db error\ndb error again\n",
+		"db/info.log":   "db info\n",
+		"http/info.log": "http info\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("unable to read %s: %v", name, err)
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, string(b))
+		}
+	}
+}
+
+func TestRoutingLogWriterErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if _, err := NewRoutingLogWriter(dir, nil, 1, 0644, nil, WithMaxSize(0)); err == nil {
+		t.Errorf("expected an error for an invalid option")
+	}
+
+	// a file where a route's directory should be
+	if err := ioutil.WriteFile(filepath.Join(dir, "db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	rw, err := NewRoutingLogWriter(dir, nil, 1, 0644, DefaultFormatterFn,
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Fatalf("unable to open new RoutingLogWriter: %v", err)
+	}
+
+	defer rw.Close()
+
+	rw.ListenerFn(time.Now(), "acme/db", Error, "lost")
+	if len(errs) != 1 {
+		t.Errorf("expected an error routing to db/error.log, got %v", errs)
+	}
+}
+
+// slowCompressor implements a Compressor that blocks until release is
+// closed.
+type slowCompressor struct {
+	release chan struct{}
+}
+
+func (c slowCompressor) Ext() string {
+	return ".gz"
+}
+
+func (c slowCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	<-c.release
+	return gzip.NewWriter(w), nil
+}
+
+func TestRoutingLogWriterEvictCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fmtFn := func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+		return format
+	}
+
+	c := slowCompressor{release: make(chan struct{})}
+	rw, err := NewRoutingLogWriter(dir, nil, 1, 0644, fmtFn, WithMaxSize(10), WithCompression(c, nil))
+	if err != nil {
+		t.Fatalf("unable to open new RoutingLogWriter: %v", err)
+	}
+
+	// rotate db/info.log, starting a compression that blocks
+	now := time.Now()
+	rw.ListenerFn(now, "acme/db", Info, "012345678")
+	rw.ListenerFn(now, "acme/db", Info, "012345678")
+
+	// evicting db/info.log must not wait for its compression
+	done := make(chan struct{})
+	go func() {
+		rw.ListenerFn(now, "acme/http", Info, "http")
+		rw.ListenerFn(now, "acme/rpc", Info, "rpc")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("routing events waited on the compression of an evicted file")
+	}
+
+	close(c.release)
+	<-done
+	if err := rw.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+
+	if files, _ := filepath.Glob(filepath.Join(dir, "db", "info.log.*.gz")); len(files) != 1 {
+		t.Errorf("expected the evicted file's backup to be compressed, got %v", files)
+	}
+}
+
+func TestRoutingLogWriterConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fmtFn := func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+		return format
+	}
+
+	rw, err := NewRoutingLogWriter(dir, nil, 2, 0644, fmtFn)
+	if err != nil {
+		t.Fatalf("unable to open new RoutingLogWriter: %v", err)
+	}
+
+	paths := []string{"a", "b", "c"}
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				rw.ListenerFn(time.Now(), path, Info, path)
+			}
+		}(path)
+	}
+	wg.Wait()
+
+	if err := rw.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(filepath.Join(dir, path, "info.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := strings.Repeat(path+"\n", 100); string(b) != expected {
+			t.Errorf("%s: expected 100 lines, got %d bytes", path, len(b))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogHandler implements slog.Handler by sending records to the
+// listeners of a Registry.  Levels are mapped onto Priority levels
+// with SlogPriority, groups are appended to the trace path as
+// additional segments, and attributes are converted to Fields.
+type SlogHandler struct {
+	tracer *Tracer
+}
+
+// NewSlogHandler returns a slog.Handler that logs records to path
+// using the listeners installed in r.  If r is nil DefaultRegistry
+// is used.
+func NewSlogHandler(r *Registry, path string) *SlogHandler {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return &SlogHandler{tracer: r.Tracer(path)}
+}
+
+// Enabled returns true if any listener is interested in records at
+// level for the handler's path.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.tracer.Enabled(SlogPriority(level))
+}
+
+// Handle sends r to the listeners interested in its level.  A zero
+// r.Time is replaced by the current time.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	m := h.tracer.match(SlogPriority(r.Level))
+	if m == nil {
+		return nil
+	}
+
+	fields := h.tracer.fields
+	if r.NumAttrs() > 0 {
+		fields = make(Fields, len(h.tracer.fields), len(h.tracer.fields)+r.NumAttrs())
+		copy(fields, h.tracer.fields)
+		r.Attrs(func(a slog.Attr) bool {
+			fields = appendAttr(fields, "", a)
+			return true
+		})
+	}
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	dispatch(m, now, fields, "%s", []interface{}{r.Message})
+	return nil
+}
+
+// WithAttrs returns a new SlogHandler whose events include attrs as
+// Fields.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields Fields
+	for _, a := range attrs {
+		fields = appendAttr(fields, "", a)
+	}
+	return &SlogHandler{tracer: h.tracer.With(fields...)}
+}
+
+// WithGroup returns a new SlogHandler whose path has name appended
+// as an additional segment.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	path := name
+	if h.tracer.path != "" {
+		path = h.tracer.path + "/" + name
+	}
+	t := h.tracer.registry.Tracer(path)
+	t.fields = h.tracer.fields
+	return &SlogHandler{tracer: t}
+}
+
+// appendAttr appends a to fields, flattening group attributes into
+// keys joined with '.'.
+func appendAttr(fields Fields, prefix string, a slog.Attr) Fields {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	if a.Key == "" {
+		return fields
+	}
+	return append(fields, Field{Key: prefix + a.Key, Value: v.Any()})
+}
+
+// SlogPriority maps a slog.Level onto a Priority.  Levels below
+// slog.LevelDebug map to Trace.
+func SlogPriority(level slog.Level) Priority {
+	switch {
+	case level < slog.LevelDebug:
+		return Trace
+	case level < slog.LevelInfo:
+		return Debug
+	case level < slog.LevelWarn:
+		return Info
+	case level < slog.LevelError:
+		return Warn
+	default:
+		return Error
+	}
+}
+
+// SlogLevel maps a Priority onto a slog.Level.  Trace maps to
+// slog.LevelDebug-4.
+func SlogLevel(priority Priority) slog.Level {
+	switch priority {
+	case Trace:
+		return slog.LevelDebug - 4
+	case Debug:
+		return slog.LevelDebug
+	case Info:
+		return slog.LevelInfo
+	case Warn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// slogListener implements EventListener by sending events to a
+// slog.Handler.
+type slogListener struct {
+	h slog.Handler
+}
+
+// NewSlogListener returns an EventListener that sends events to h,
+// allowing a slog.Handler to be installed with RegisterListener.  The
+// trace path is recorded in the attribute "path", and Fields are
+// converted to attributes.
+func NewSlogListener(h slog.Handler) EventListener {
+	return &slogListener{h: h}
+}
+
+func (l *slogListener) Listen(e *Event) {
+	ctx := context.Background()
+	level := SlogLevel(e.Priority)
+	if !l.h.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(e.Time, level, e.Message(), 0)
+	r.AddAttrs(slog.String("path", e.Path))
+	for _, f := range e.Fields {
+		r.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+
+	l.h.Handle(ctx, r)
+}
@@ -0,0 +1,105 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogHandler(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var events []*Event
+	handle := r.RegisterListener(Prefix("slog"), AtLeast(Info), EventListenerFn(func(e *Event) {
+		events = append(events, e)
+	}))
+	defer handle.Remove()
+
+	logger := slog.New(NewSlogHandler(r, "slog"))
+
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Errorf("expected slog.LevelDebug to be disabled")
+	}
+	if !logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Errorf("expected slog.LevelInfo to be enabled")
+	}
+
+	logger.Debug("dropped")
+	logger.With("request", "abc").WithGroup("db").Warn("slow query", "elapsed", 3, slog.Group("conn", "id", 7))
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+
+	e := events[0]
+	if e.Path != "slog/db" || e.Priority != Warn || e.Message() != "slow query" {
+		t.Errorf("unexpected event: path %q priority %s message %q", e.Path, e.Priority, e.Message())
+	}
+	if s := e.Fields.String(); s != "request=abc elapsed=3 conn.id=7" {
+		t.Errorf("unexpected fields [%s]", s)
+	}
+}
+
+func TestSlogHandlerZeroTime(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var events []*Event
+	handle := r.RegisterListener(Prefix("slog"), AtLeast(Info), EventListenerFn(func(e *Event) {
+		events = append(events, e)
+	}))
+	defer handle.Remove()
+
+	before := time.Now()
+	h := NewSlogHandler(r, "slog")
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "no time", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].Time.Before(before) {
+		t.Errorf("expected a zero record time to be replaced by the current time, got %s", events[0].Time)
+	}
+}
+
+func TestSlogPriority(t *testing.T) {
+	for p := Trace; p < None; p++ {
+		if actual := SlogPriority(SlogLevel(p)); actual != p {
+			t.Errorf("expected %s to round trip, got %s", p, actual)
+		}
+	}
+	if p := SlogPriority(slog.LevelError + 4); p != Error {
+		t.Errorf("expected levels above slog.LevelError to map to Error, got %s", p)
+	}
+}
+
+func TestSlogListener(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	handle := r.RegisterListener(Prefix(""), AllPriorities, NewSlogListener(h))
+	defer handle.Remove()
+
+	tr := r.Tracer("github.com/acme/db").With(Int("n", 1))
+	tr.Debugf("dropped")
+	tr.Infof("hello %s", "world")
+
+	e := `level=INFO msg="hello world" path=github.com/acme/db n=1`
+	if s := strings.TrimSpace(buf.String()); s != e {
+		t.Errorf("expected [%s], got [%s]", e, s)
+	}
+}
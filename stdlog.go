@@ -0,0 +1,158 @@
+package trace
+
+import (
+	"log"
+	"strings"
+)
+
+// StdLogWriter implements an io.Writer that turns each call to Write
+// into a trace event, allowing the output of the standard log package
+// to be routed to trace listeners, e.g.:
+//
+//	log.SetFlags(0)
+//	log.SetOutput(trace.NewStdLogWriter(nil, "github.com/acme/vendor", trace.Info, true))
+//
+// A log.Logger calls Write once per message, so a message spanning
+// multiple lines is delivered as a single event.
+type StdLogWriter struct {
+	tracer   *Tracer
+	priority Priority
+	sniff    bool
+	// logger is the log.Logger writing to w, its prefix and flags are
+	// skipped when sniffing for a level.  When nil the standard logger
+	// is assumed.
+	logger *log.Logger
+}
+
+// NewStdLogWriter returns a StdLogWriter that logs to path using the
+// listeners installed in r, or DefaultRegistry if r is nil.  Events
+// are logged at priority unless sniff is true and the message starts
+// with a recognized level, such as "ERROR:" or "[warn]", in which case
+// that level is used instead.  When sniffing, the prefix and header
+// written by the standard logger, as set by log.SetPrefix and
+// log.SetFlags, are skipped; use NewStdLogger to sniff the output of
+// another log.Logger.
+func NewStdLogWriter(r *Registry, path string, priority Priority, sniff bool) *StdLogWriter {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return &StdLogWriter{
+		tracer:   r.Tracer(path),
+		priority: priority,
+		sniff:    sniff,
+	}
+}
+
+// NewStdLogger returns a *log.Logger that writes to a StdLogWriter.
+// The prefix and flag are passed to log.New; since listeners receive
+// the time of each event, flag is usually 0.
+func NewStdLogger(r *Registry, path string, priority Priority, sniff bool, prefix string, flag int) *log.Logger {
+	w := NewStdLogWriter(r, path, priority, sniff)
+	w.logger = log.New(w, prefix, flag)
+	return w.logger
+}
+
+// Write logs p as a single event, removing a trailing newline.  It
+// always reports that all of p was written.
+func (w *StdLogWriter) Write(p []byte) (n int, err error) {
+	priority := w.priority
+	if w.sniff {
+		logger := w.logger
+		if logger == nil {
+			logger = log.Default()
+		}
+		if sniffed, ok := sniffPriority(skipHeader(p, logger.Prefix(), logger.Flags())); ok {
+			priority = sniffed
+		}
+	}
+
+	if w.tracer.Enabled(priority) {
+		msg := strings.TrimSuffix(string(p), "\n")
+		w.tracer.Logf(priority, "%s", msg)
+	}
+
+	return len(p), nil
+}
+
+// sniffPriorities maps the level names recognized by sniffPriority
+// onto a Priority.
+var sniffPriorities = map[string]Priority{
+	"TRACE":   Trace,
+	"DEBUG":   Debug,
+	"INFO":    Info,
+	"WARN":    Warn,
+	"WARNING": Warn,
+	"ERROR":   Error,
+	"ERR":     Error,
+	"FATAL":   Error,
+	"PANIC":   Error,
+}
+
+// skipHeader returns p without the prefix and header that a
+// log.Logger with the specified prefix and flags writes before each
+// message.  If p does not start with the expected header it is
+// returned unchanged.
+func skipHeader(p []byte, prefix string, flags int) []byte {
+	s := string(p)
+	if flags&log.Lmsgprefix == 0 {
+		if !strings.HasPrefix(s, prefix) {
+			return p
+		}
+		s = s[len(prefix):]
+	}
+
+	n := 0
+	if flags&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		if flags&log.Ldate != 0 {
+			n += len("2006/01/02 ")
+		}
+		if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+			n += len("15:04:05 ")
+			if flags&log.Lmicroseconds != 0 {
+				n += len(".000000")
+			}
+		}
+		if len(s) < n {
+			return p
+		}
+		s = s[n:]
+	}
+
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		i := strings.Index(s, ": ")
+		if i < 0 {
+			return p
+		}
+		s = s[i+2:]
+	}
+
+	if flags&log.Lmsgprefix != 0 {
+		if !strings.HasPrefix(s, prefix) {
+			return p
+		}
+		s = s[len(prefix):]
+	}
+
+	return []byte(s)
+}
+
+// sniffPriority looks for a level name at the start of p, either
+// enclosed in brackets, e.g., "[warn]", or followed by a colon, e.g.,
+// "ERROR:".
+func sniffPriority(p []byte) (Priority, bool) {
+	s := strings.TrimLeft(string(p[:min(len(p), 16)]), " \t")
+
+	var end int
+	if strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end = strings.IndexByte(s, ']')
+	} else {
+		end = strings.IndexByte(s, ':')
+	}
+	if end < 1 {
+		return Error, false
+	}
+
+	priority, ok := sniffPriorities[strings.ToUpper(s[:end])]
+	return priority, ok
+}
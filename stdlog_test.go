@@ -0,0 +1,112 @@
+package trace
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+func TestStdLogWriter(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var events []*Event
+	handle := r.RegisterListener(Prefix("stdlog"), AllPriorities, EventListenerFn(func(e *Event) {
+		events = append(events, e)
+	}))
+	defer handle.Remove()
+
+	logger := NewStdLogger(r, "stdlog", Info, true, "", 0)
+
+	tests := []struct {
+		msg      string
+		priority Priority
+	}{
+		{"plain message", Info},
+		{"ERROR: disk full", Error},
+		{"[warn] retrying", Warn},
+		{"Warning: deprecated", Warn},
+		{"debug: details", Debug},
+		{"debug details", Info},
+		{"Error handling middleware installed", Info},
+		{"trace id assigned", Info},
+		{"[warn retrying", Info},
+		{"ERRORS are not levels", Info},
+		{"first line\nsecond line", Info},
+	}
+
+	for _, v := range tests {
+		logger.Print(v.msg)
+	}
+
+	if len(events) != len(tests) {
+		t.Fatalf("expected %d events, got %d", len(tests), len(events))
+	}
+
+	for i, v := range tests {
+		e := events[i]
+		if e.Path != "stdlog" || e.Priority != v.priority || e.Message() != v.msg {
+			t.Errorf("[%d] expected %s %q, got %s %q", i, v.priority, v.msg, e.Priority, e.Message())
+		}
+	}
+}
+
+func TestStdLogWriterNoSniff(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var seen []Priority
+	handle := r.RegisterListener(Prefix(""), AllPriorities, EventListenerFn(func(e *Event) {
+		seen = append(seen, e.Priority)
+	}))
+	defer handle.Remove()
+
+	w := NewStdLogWriter(r, "stdlog", Debug, false)
+	if n, err := w.Write([]byte("ERROR: not sniffed\n")); n != 19 || err != nil {
+		t.Errorf("unexpected Write result: %d %v", n, err)
+	}
+
+	if len(seen) != 1 || seen[0] != Debug {
+		t.Errorf("expected a single Debug event, got %v", seen)
+	}
+}
+
+func TestStdLogWriterHeader(t *testing.T) {
+	r := NewRegistry(nil)
+
+	var seen []Priority
+	handle := r.RegisterListener(Prefix(""), AllPriorities, EventListenerFn(func(e *Event) {
+		seen = append(seen, e.Priority)
+	}))
+	defer handle.Remove()
+
+	for _, flag := range []int{
+		0,
+		log.LstdFlags,
+		log.Ldate | log.Lmicroseconds | log.LUTC,
+		log.LstdFlags | log.Lshortfile,
+		log.LstdFlags | log.Llongfile | log.Lmsgprefix,
+	} {
+		seen = seen[:0]
+		logger := NewStdLogger(r, "stdlog", Info, true, "app: ", flag)
+		logger.Print("ERROR: disk full")
+		logger.Print("Error handling middleware installed")
+
+		if len(seen) != 2 || seen[0] != Error || seen[1] != Info {
+			t.Errorf("flag %d: expected [Error Info], got %v", flag, seen)
+		}
+	}
+
+	// the standard logger keeps log.LstdFlags by default
+	defer log.SetOutput(os.Stderr)
+	defer log.SetPrefix(log.Prefix())
+	defer log.SetFlags(log.Flags())
+
+	seen = seen[:0]
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("std ")
+	log.SetOutput(NewStdLogWriter(r, "stdlog", Info, true))
+	log.Print("[warn] retrying")
+
+	if len(seen) != 1 || seen[0] != Warn {
+		t.Errorf("expected [Warn] from the standard logger, got %v", seen)
+	}
+}
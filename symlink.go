@@ -0,0 +1,56 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithSymlink returns a LogWriterOption that maintains a symbolic link
+// named name, in the log directory, pointing to the current log file.
+// This gives a LogWriter created with NewTimeLogWriter a fixed path
+// that tools such as tail -F can follow, e.g., app.log pointing to
+// 2006-01-02.log.  The link is replaced atomically each time the
+// LogWriter switches files, and an existing file with the same name
+// that is not a symbolic link is never replaced.
+func WithSymlink(name string) LogWriterOption {
+	return func(w *LogWriter) error {
+		if name == "" {
+			return fmt.Errorf("WithSymlink: name must not be empty")
+		}
+		w.symlink = name
+		return nil
+	}
+}
+
+// updateSymlink points w.symlink at path, if it is not already, by
+// creating a temporary link and renaming it over the old one.  The
+// caller must hold w.mu.
+func (w *LogWriter) updateSymlink(path string) error {
+	link := filepath.Join(w.dir, w.symlink)
+
+	target, err := filepath.Rel(filepath.Dir(link), path)
+	if err != nil {
+		target = path
+	}
+
+	if fi, err := os.Lstat(link); err == nil {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("unable to update symlink %s: file exists and is not a symlink", link)
+		}
+		if cur, err := os.Readlink(link); err == nil && cur == target {
+			return nil
+		}
+	}
+
+	tmp := fmt.Sprintf("%s.%d.tmp", link, os.Getpid())
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("unable to update symlink %s: %v", link, err)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to update symlink %s: %v", link, err)
+	}
+	return nil
+}
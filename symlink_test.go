@@ -0,0 +1,81 @@
+package trace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogWriterSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	name := "2006-01-02.15:04:05.log"
+	w, err := NewTimeLogWriter(dir, name, 0644, DefaultFormatterFn, WithSymlink("current.log"))
+	if err != nil {
+		t.Fatalf("NewTimeLogWriter error: %v", err)
+	}
+
+	defer w.Close()
+
+	link := filepath.Join(dir, "current.log")
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			// wait for the next second, so the time-based name changes
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		}
+
+		w.Write([]byte("hello\n"))
+
+		target, err := os.Readlink(link)
+		if err != nil {
+			t.Fatalf("unable to read symlink: %v", err)
+		}
+		if expected := filepath.Base(w.Name()); target != expected {
+			t.Errorf("expected %s to point to %s, got %s", link, expected, target)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(files) != 0 {
+		t.Errorf("expected no temporary links to remain, got %v", files)
+	}
+}
+
+func TestLogWriterSymlinkExists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_logfile.")
+	if err != nil {
+		t.Fatalf("unable to open tempfile: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	link := filepath.Join(dir, "current.log")
+	if err := ioutil.WriteFile(link, []byte("keep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	w, err := NewLogWriter(dir, "test.log", 0644, DefaultFormatterFn,
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+		WithSymlink("current.log"))
+	if err != nil {
+		t.Fatalf("unable to open new LogWriter: %v", err)
+	}
+
+	defer w.Close()
+
+	if len(errs) != 1 {
+		t.Errorf("expected an error replacing a regular file, got %v", errs)
+	}
+	if b, _ := ioutil.ReadFile(link); string(b) != "keep\n" {
+		t.Errorf("expected %s to be left alone, got %q", link, string(b))
+	}
+}
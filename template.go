@@ -0,0 +1,358 @@
+package trace
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewTemplateFormatterFn compiles pattern into a FormatterFn.  The
+// pattern is copied to the output, with the exception of "%%", which
+// produces a single '%', and verbs of the form %{name} or
+// %{name:options}:
+//
+//	%{time}          the time formatted with time.RFC3339 in local time
+//	%{time:LAYOUT}   the local time formatted with the time.Format LAYOUT
+//	%{utctime}       as %{time}, converting the time to UTC first
+//	%{level}         the Priority, as produced by Priority.String()
+//	%{level:OPTS}    a comma separated list of "upper", "lower", and a
+//	                 minimum width, e.g., %{level:upper,5}
+//	%{path}          the trace path
+//	%{path:short}    the last segment of the trace path
+//	%{path:N}        the last N segments of the trace path
+//	%{msg}           the message, followed by any Fields unless the
+//	                 pattern also contains %{fields}
+//	%{fields}        the Fields, as produced by Fields.String()
+//	%{caller}        the file:line that logged the event
+//	%{caller:long}   as %{caller}, with the full path of the file
+//
+// For example:
+//
+//	%{time:2006-01-02T15:04:05.000} %{level:5} %{path:short} %{msg}
+func NewTemplateFormatterFn(pattern string) (FormatterFn, error) {
+	parts, err := compileTemplate(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	withFields := true
+	for _, p := range parts {
+		if _, ok := p.(templateFields); ok {
+			withFields = false
+		}
+	}
+
+	fn := func(t time.Time, path string, priority Priority, format string, args ...interface{}) string {
+		ev := &templateEvent{
+			t:          t,
+			path:       path,
+			priority:   priority,
+			format:     format,
+			args:       args,
+			withFields: withFields,
+		}
+		var b strings.Builder
+		for _, p := range parts {
+			p.write(&b, ev)
+		}
+		return b.String()
+	}
+
+	return fn, nil
+}
+
+// templateEvent holds the arguments to a template FormatterFn.
+type templateEvent struct {
+	t          time.Time
+	path       string
+	priority   Priority
+	format     string
+	args       []interface{}
+	withFields bool
+}
+
+// templatePart writes a single literal or verb of a template.
+type templatePart interface {
+	write(b *strings.Builder, e *templateEvent)
+}
+
+// compileTemplate splits pattern into a list of templatePart.
+func compileTemplate(pattern string) ([]templatePart, error) {
+	var parts []templatePart
+	var literal strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' {
+			literal.WriteByte(c)
+			continue
+		}
+		if i+1 < len(pattern) && pattern[i+1] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+		if i+1 >= len(pattern) || pattern[i+1] != '{' {
+			return nil, fmt.Errorf("template %q: expected %%{ or %%%% at offset %d", pattern, i)
+		}
+
+		end := strings.IndexByte(pattern[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("template %q: unterminated verb at offset %d", pattern, i)
+		}
+		verb := pattern[i+2 : i+end]
+
+		part, err := compileVerb(verb)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %%{%s} at offset %d: %v", pattern, verb, i, err)
+		}
+
+		if literal.Len() > 0 {
+			parts = append(parts, templateLiteral(literal.String()))
+			literal.Reset()
+		}
+		parts = append(parts, part)
+		i += end
+	}
+
+	if literal.Len() > 0 {
+		parts = append(parts, templateLiteral(literal.String()))
+	}
+
+	return parts, nil
+}
+
+// compileVerb returns the templatePart for a verb of the form
+// name or name:options.
+func compileVerb(verb string) (templatePart, error) {
+	name, opts, hasOpts := strings.Cut(verb, ":")
+
+	switch name {
+	case "time", "utctime":
+		layout := time.RFC3339
+		if hasOpts {
+			if opts == "" {
+				return nil, fmt.Errorf("empty time layout")
+			}
+			layout = opts
+		}
+		return templateTime{layout: layout, utc: name == "utctime"}, nil
+
+	case "level":
+		var l templateLevel
+		if hasOpts {
+			for _, opt := range strings.Split(opts, ",") {
+				switch opt {
+				case "upper":
+					l.upper = true
+				case "lower":
+					l.lower = true
+				default:
+					width, err := strconv.Atoi(opt)
+					if err != nil || width < 0 {
+						return nil, fmt.Errorf("unknown level option %q", opt)
+					}
+					l.width = width
+				}
+			}
+		}
+		return l, nil
+
+	case "path":
+		if !hasOpts {
+			return templatePath(0), nil
+		}
+		if opts == "short" {
+			return templatePath(1), nil
+		}
+		n, err := strconv.Atoi(opts)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("unknown path option %q", opts)
+		}
+		return templatePath(n), nil
+
+	case "msg":
+		if hasOpts {
+			return nil, fmt.Errorf("msg does not accept options")
+		}
+		return templateMsg{}, nil
+
+	case "fields":
+		if hasOpts {
+			return nil, fmt.Errorf("fields does not accept options")
+		}
+		return templateFields{}, nil
+
+	case "caller":
+		switch {
+		case !hasOpts:
+			return templateCaller(false), nil
+		case opts == "long":
+			return templateCaller(true), nil
+		default:
+			return nil, fmt.Errorf("unknown caller option %q", opts)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown verb %q", name)
+}
+
+// templateLiteral writes a literal string.
+type templateLiteral string
+
+func (l templateLiteral) write(b *strings.Builder, e *templateEvent) {
+	b.WriteString(string(l))
+}
+
+// templateTime writes the event time.
+type templateTime struct {
+	layout string
+	utc    bool
+}
+
+func (v templateTime) write(b *strings.Builder, e *templateEvent) {
+	t := e.t
+	if v.utc {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	b.WriteString(t.Format(v.layout))
+}
+
+// templateLevel writes the event Priority.
+type templateLevel struct {
+	upper bool
+	lower bool
+	width int
+}
+
+func (v templateLevel) write(b *strings.Builder, e *templateEvent) {
+	s := e.priority.String()
+	if v.upper {
+		s = strings.ToUpper(s)
+	} else if v.lower {
+		s = strings.ToLower(s)
+	}
+	b.WriteString(s)
+	for i := len(s); i < v.width; i++ {
+		b.WriteByte(' ')
+	}
+}
+
+// templatePath writes the last n segments of the event path, or the
+// whole path if n is 0.
+type templatePath int
+
+func (v templatePath) write(b *strings.Builder, e *templateEvent) {
+	path := e.path
+	if v > 0 {
+		end := len(path)
+		for n := 0; n < int(v); n++ {
+			i := strings.LastIndexByte(path[:end], '/')
+			if i < 0 {
+				end = -1
+				break
+			}
+			end = i
+		}
+		path = path[end+1:]
+	}
+	b.WriteString(path)
+}
+
+// templateMsg writes the event message.
+type templateMsg struct{}
+
+func (v templateMsg) write(b *strings.Builder, e *templateEvent) {
+	if ev := EventFromArgs(e.format, e.args); ev != nil {
+		if e.withFields {
+			b.WriteString(ev.String())
+		} else {
+			b.WriteString(ev.Message())
+		}
+		return
+	}
+	b.WriteString(fmt.Sprintf(e.format, e.args...))
+}
+
+// templateFields writes the event Fields.
+type templateFields struct{}
+
+func (v templateFields) write(b *strings.Builder, e *templateEvent) {
+	if _, _, fields := SplitFields(e.format, e.args); len(fields) > 0 {
+		b.WriteString(fields.String())
+	}
+}
+
+// templateCaller writes the file and line that logged the event, the
+// full path of the file if long is true.
+type templateCaller bool
+
+func (long templateCaller) write(b *strings.Builder, e *templateEvent) {
+	file, line := caller()
+	if file == "" {
+		b.WriteString("???")
+		return
+	}
+	if !long {
+		file = filepath.Base(file)
+	}
+	b.WriteString(file)
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(line))
+}
+
+// packageDir holds the directory containing the source of this
+// package, it is used by caller to skip frames within the package.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// dispatchName holds the function name of dispatch, which separates
+// the frames of the listeners from the frames of the code that logged
+// the event.
+var dispatchName = runtime.FuncForPC(reflect.ValueOf(dispatch).Pointer()).Name()
+
+// caller returns the file and line of the code that logged the event
+// being formatted: the first stack frame beyond dispatch that is
+// outside of this package and the standard log and log/slog packages.
+// If dispatch is not on the stack the search starts from the caller
+// of the FormatterFn.
+func caller() (file string, line int) {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(3, pc)
+
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(pc[:n])
+	for {
+		f, more := iter.Next()
+		frames = append(frames, f)
+		if !more {
+			break
+		}
+	}
+
+	start := 0
+	for i, f := range frames {
+		if f.Function == dispatchName {
+			start = i + 1
+			break
+		}
+	}
+
+	for _, f := range frames[start:] {
+		inPackage := filepath.Dir(f.File) == packageDir && !strings.HasSuffix(f.File, "_test.go")
+		inLog := strings.HasPrefix(f.Function, "log.") || strings.HasPrefix(f.Function, "log/slog.")
+		if !inPackage && !inLog && f.File != "" {
+			return f.File, f.Line
+		}
+	}
+
+	return "", 0
+}
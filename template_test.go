@@ -0,0 +1,100 @@
+package trace
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateFormatterFn(t *testing.T) {
+	// %{time} converts the input to local time, %{utctime} to UTC
+	tm := time.Date(2006, 1, 2, 15, 4, 5, 123000000, time.FixedZone("MST", -7*60*60))
+
+	tests := []struct {
+		pattern  string
+		path     string
+		expected string
+	}{
+		{"%{time:2006-01-02T15:04:05.000Z07:00} %{level:5} %{path:short} %{msg}", "github.com/acme/db", tm.Local().Format("2006-01-02T15:04:05.000Z07:00") + " Warn  db hello 3"},
+		{"%{utctime:15:04:05} [%{level:upper}] %{path:2}: %{msg}", "github.com/acme/db", "22:04:05 [WARN] acme/db: hello 3"},
+		{"%{time} %{level:lower,6}|%{path}", "github.com/acme/db", tm.Local().Format(time.RFC3339) + " warn  |github.com/acme/db"},
+		{"%{path:5} 100%% %{msg}", "github.com/acme/db", "github.com/acme/db 100% hello 3"},
+		{"%{path:short}", "", ""},
+	}
+
+	for _, v := range tests {
+		fn, err := NewTemplateFormatterFn(v.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", v.pattern, err)
+			continue
+		}
+		if s := fn(tm, v.path, Warn, "hello %d", 3); s != v.expected {
+			t.Errorf("%s: expected [%s], got [%s]", v.pattern, v.expected, s)
+		}
+	}
+}
+
+func TestTemplateFormatterFnFields(t *testing.T) {
+	withMsg, err := NewTemplateFormatterFn("%{msg}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	separate, err := NewTemplateFormatterFn("%{msg} {%{fields}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Event{Format: "hello", Fields: Fields{Int("n", 1)}}
+	if s := withMsg(e.Time, e.Path, e.Priority, eventVerb, e); s != "hello n=1" {
+		t.Errorf("expected [hello n=1], got [%s]", s)
+	}
+	if s := separate(e.Time, e.Path, e.Priority, eventVerb, e); s != "hello {n=1}" {
+		t.Errorf("expected [hello {n=1}], got [%s]", s)
+	}
+}
+
+func TestTemplateFormatterFnCaller(t *testing.T) {
+	fn, err := NewTemplateFormatterFn("%{caller} %{msg}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry(nil)
+	var seen string
+	handle := r.Register("", Trace, func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+		seen = fn(t, p, n, format, args...)
+	})
+	defer handle.Remove()
+
+	_, _, line, _ := runtime.Caller(0)
+	r.Tracer("caller").Infof("hello")
+
+	expected := fmt.Sprintf("template_test.go:%d hello", line+1)
+	if seen != expected {
+		t.Errorf("expected [%s], got [%s]", expected, seen)
+	}
+}
+
+func TestTemplateFormatterFnErrors(t *testing.T) {
+	for _, pattern := range []string{
+		"%{bogus}",
+		"%{level:wide}",
+		"%{path:0}",
+		"%{time:}",
+		"%{caller:short}",
+		"%{msg",
+		"100%",
+		"%d",
+	} {
+		if _, err := NewTemplateFormatterFn(pattern); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+	}
+
+	_, err := NewTemplateFormatterFn("%{time} %{bogus}")
+	if err == nil || !strings.Contains(err.Error(), `unknown verb "bogus"`) {
+		t.Errorf("expected error to name the unknown verb, got %v", err)
+	}
+}
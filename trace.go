@@ -1,89 +1,94 @@
 package trace
 
 import (
-	"sync"
 	"time"
 )
 
-// lock is a global mutex for the listener registry
-var lock = new(sync.RWMutex)
+// Register installs a new listener in DefaultRegistry for paths
+// equal to or below prefix
+func Register(prefix string, min Priority, fn ListenerFn) listenerHandle {
+	return DefaultRegistry.Register(prefix, min, fn)
+}
 
-// registry is a global registry of listeners
-var registry = make([]*listener, 0)
+// RegisterPattern installs a new listener in DefaultRegistry for
+// paths matched by pattern
+func RegisterPattern(pattern Pattern, min Priority, fn ListenerFn) listenerHandle {
+	return DefaultRegistry.RegisterPattern(pattern, min, fn)
+}
 
-// Register installs a new listener
-func Register(prefix string, min Priority, fn ListenerFn) listenerHandle {
-	lock.Lock()
-	defer lock.Unlock()
-	registry = append(registry, newListener(prefix, min, fn))
-	return listenerHandle(len(registry) - 1)
+// RegisterPriorities installs a new listener in DefaultRegistry for
+// paths matched by pattern, accepting only the Priority levels in
+// priorities
+func RegisterPriorities(pattern Pattern, priorities Priorities, fn ListenerFn) listenerHandle {
+	return DefaultRegistry.RegisterPriorities(pattern, priorities, fn)
 }
 
-// M searches for any listener matching the specified path and
-// priority level.  When ok is true the returned match should be
-// returned to the library via functions T or D.
+// M searches DefaultRegistry for any listener matching the specified
+// path and priority level.  When ok is true the returned match should
+// be returned to the library via functions T or D.
 func M(path string, priority Priority) (match []listenerMatch, ok bool) {
-	lock.RLock()
-	defer lock.RUnlock()
-
-	if len(registry) == 0 {
-		return
-	}
+	return DefaultRegistry.M(path, priority)
+}
 
-	npath := len(path)
+// RegisterListener installs a new EventListener in DefaultRegistry
+// for paths matched by pattern, accepting only the Priority levels in
+// priorities
+func RegisterListener(pattern Pattern, priorities Priorities, el EventListener) listenerHandle {
+	return DefaultRegistry.RegisterListener(pattern, priorities, el)
+}
 
-	match = make([]listenerMatch, len(registry))
-	nmatch := 0
+// T logs the format and args to each listener function in match
+func T(match []listenerMatch, format string, args ...interface{}) {
+	if match != nil {
+		dispatch(match, time.Now(), nil, format, args)
+	}
+}
 
-	for _, l := range registry {
-		if priority < l.min {
+// dispatch logs the format, args and fields to each listener in
+// match, using now as the time of the event.  A ListenerFn is always
+// called with the original format and args, other listeners share a
+// single Event so the message is formatted at most once.
+func dispatch(match []listenerMatch, now time.Time, fields Fields, format string, args []interface{}) {
+	var e *Event
+	for i := range match {
+		m := &match[i]
+		if m.fn != nil {
+			m.fn(now, m.path, m.priority, format, args...)
 			continue
 		}
-		if n := len(l.prefix); n > 0 {
-			if !(npath >= n && path[0:n] == l.prefix) {
-				continue
-			}
-			if npath > n && path[n] != '/' {
-				continue
+		if e == nil || e.Path != m.path || e.Priority != m.priority {
+			e = &Event{
+				Time:     now,
+				Path:     m.path,
+				Priority: m.priority,
+				Format:   format,
+				Args:     args,
+				Fields:   fields,
 			}
 		}
-		match[nmatch] = newListenerMatch(path, priority, l)
-		nmatch++
+		m.el.Listen(e)
 	}
-
-	match = match[0:nmatch]
-	return match, len(match) > 0
 }
 
-// T logs the format and args to each listener function in match
-func T(match []listenerMatch, format string, args ...interface{}) {
-	if match != nil {
-		now := time.Now()
-		for i := range match {
-			match[i].fn(now, match[i].path, match[i].priority, format, args...)
-		}
-	}
+// listenerHandle provides a method to remove a Listener from the
+// registry.  The handle holds the unique id assigned to the listener
+// by Register, so it remains valid when other listeners are removed.
+type listenerHandle struct {
+	registry *Registry
+	id       uint64
 }
 
-// listenerHandle provides a method to remove a Listener from the registry
-type listenerHandle int
-
-// Remove uninstalls a listener
-func (h listenerHandle) Remove() {
-	lock.Lock()
-	defer lock.Unlock()
+// Id returns the unique id assigned to the listener by Register.
+func (h listenerHandle) Id() uint64 {
+	return h.id
+}
 
-	n := len(registry)
-	i := int(h)
-	if i == 0 {
-		if n > 1 {
-			registry = registry[1:]
-		} else {
-			registry = []*listener{}
-		}
-	} else if i == n {
-		registry = registry[0:n]
-	} else {
-		registry = append(registry[0:i], registry[i+1:]...)
+// Remove uninstalls a listener, returning true if the listener was
+// found in the registry.  It is safe to call Remove more than once;
+// subsequent calls will return false.
+func (h listenerHandle) Remove() bool {
+	if h.registry == nil {
+		return false
 	}
+	return h.registry.remove(h.id)
 }
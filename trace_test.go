@@ -2,6 +2,7 @@ package trace
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -139,6 +140,7 @@ func BenchmarkFunctionCall(b *testing.B) {
 }
 
 func BenchmarkNoListeners(b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		if m, ok := M("/trace/a/b", Info); ok {
 			T(m, "/trace/a/b", Info, "%d\n", i)
@@ -147,6 +149,7 @@ func BenchmarkNoListeners(b *testing.B) {
 }
 
 func BenchmarkOtherListeners(b *testing.B) {
+	b.ReportAllocs()
 	for _, path := range []string{"path1", "path2"} {
 		handle := Register(path, Info, discardListenerFn)
 		defer handle.Remove()
@@ -159,6 +162,7 @@ func BenchmarkOtherListeners(b *testing.B) {
 }
 
 func BenchmarkFirstListener(b *testing.B) {
+	b.ReportAllocs()
 	for _, path := range []string{"path1", "path2"} {
 		handle := Register(path, Info, discardListenerFn)
 		defer handle.Remove()
@@ -171,6 +175,7 @@ func BenchmarkFirstListener(b *testing.B) {
 }
 
 func BenchmarkSecondListener(b *testing.B) {
+	b.ReportAllocs()
 	for _, path := range []string{"path1", "path2"} {
 		handle := Register(path, Info, discardListenerFn)
 		defer handle.Remove()
@@ -183,6 +188,7 @@ func BenchmarkSecondListener(b *testing.B) {
 }
 
 func BenchmarkTwoListeners(b *testing.B) {
+	b.ReportAllocs()
 	for _, path := range []string{"/trace", "/trace/a"} {
 		handle := Register(path, Info, discardListenerFn)
 		defer handle.Remove()
@@ -195,6 +201,7 @@ func BenchmarkTwoListeners(b *testing.B) {
 }
 
 func BenchmarkThreeListeners(b *testing.B) {
+	b.ReportAllocs()
 	for _, path := range []string{"/trace", "/trace/a", "/trace/a/b"} {
 		handle := Register(path, Info, discardListenerFn)
 		defer handle.Remove()
@@ -206,6 +213,97 @@ func BenchmarkThreeListeners(b *testing.B) {
 	}
 }
 
+func BenchmarkParallelOtherListeners(b *testing.B) {
+	for _, path := range []string{"path1", "path2"} {
+		handle := Register(path, Info, discardListenerFn)
+		defer handle.Remove()
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if m, ok := M("/elsewhere", Info); ok {
+				T(m, "/elsewhere", Info, "%d\n", i)
+			}
+			i++
+		}
+	})
+}
+
+// formatListenerFn formats each message with DefaultFormatterFn and
+// discards the result, as a LogWriter or MemLog would before writing.
+func formatListenerFn(t time.Time, p string, n Priority, format string, args ...interface{}) {
+	DefaultFormatterFn(t, p, n, format, args...)
+}
+
+// formatBenchmarkArg is a moderately sized value to log, so that the
+// cost of formatting the message is visible.
+var formatBenchmarkArg = map[string][]int{
+	"a": {1, 2, 3, 4, 5, 6, 7, 8},
+	"b": {9, 10, 11, 12, 13, 14, 15, 16},
+	"c": {17, 18, 19, 20, 21, 22, 23, 24},
+}
+
+// formatEventListener formats each Event it receives, reusing the
+// message shared with the other listeners.
+var formatEventListener = EventListenerFn(func(e *Event) {
+	formatEvent(DefaultFormatterFn, e)
+})
+
+// benchmarkFormatListeners registers n formatting listeners and logs to
+// them, either as EventListeners sharing a single Event, or as
+// ListenerFns that each format the message themselves.
+func benchmarkFormatListeners(b *testing.B, n int, shared bool) {
+	r := NewRegistry(nil)
+	for i := 0; i < n; i++ {
+		var handle listenerHandle
+		if shared {
+			handle = r.RegisterListener(Prefix("/trace"), AtLeast(Info), formatEventListener)
+		} else {
+			handle = r.Register("/trace", Info, formatListenerFn)
+		}
+		defer handle.Remove()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if m, ok := r.M("/trace/a/b", Info); ok {
+			T(m, "iteration %d of %d: %v", i, b.N, formatBenchmarkArg)
+		}
+	}
+}
+
+func BenchmarkFormatTwoListenersPerListener(b *testing.B) {
+	benchmarkFormatListeners(b, 2, false)
+}
+
+func BenchmarkFormatTwoListenersShared(b *testing.B) {
+	benchmarkFormatListeners(b, 2, true)
+}
+
+func BenchmarkFormatThreeListenersPerListener(b *testing.B) {
+	benchmarkFormatListeners(b, 3, false)
+}
+
+func BenchmarkFormatThreeListenersShared(b *testing.B) {
+	benchmarkFormatListeners(b, 3, true)
+}
+
+func TestMNoMatchAllocs(t *testing.T) {
+	for _, path := range []string{"path1", "path2"} {
+		handle := Register(path, Info, discardListenerFn)
+		defer handle.Remove()
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, ok := M("/elsewhere", Info); ok {
+			t.Error("unexpected match for /elsewhere")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected M to make 0 allocations when nothing matches, got %v", allocs)
+	}
+}
+
 func TestDefaultFormatterFn(t *testing.T) {
 	tm, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05-07:00")
 	if err != nil {
@@ -219,3 +317,77 @@ func TestDefaultFormatterFn(t *testing.T) {
 		t.Errorf("expected [%s], got [%s]", e, s)
 	}
 }
+
+func TestHandleRemoveStable(t *testing.T) {
+	seen := make(map[string]int)
+	listenerFn := func(name string) ListenerFn {
+		return func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+			seen[name]++
+		}
+	}
+
+	a := Register("stable", Info, listenerFn("a"))
+	b := Register("stable", Info, listenerFn("b"))
+	c := Register("stable", Info, listenerFn("c"))
+	defer c.Remove()
+
+	if !a.Remove() {
+		t.Errorf("expected first Remove of a to report true")
+	}
+	if a.Remove() {
+		t.Errorf("expected second Remove of a to report false")
+	}
+	if !b.Remove() {
+		t.Errorf("expected Remove of b to report true after removing a")
+	}
+
+	if m, ok := M("stable", Info); ok {
+		T(m, "hello")
+	}
+
+	if seen["a"] != 0 || seen["b"] != 0 {
+		t.Errorf("removed listeners were called: %v", seen)
+	}
+	if seen["c"] != 1 {
+		t.Errorf("expected listener c to be called once, got %d", seen["c"])
+	}
+}
+
+func TestHandleRemoveConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	called := make(map[int]int)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("concurrent/%d", i)
+			handle := Register(path, Info, func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+				mu.Lock()
+				called[i]++
+				mu.Unlock()
+			})
+			if m, ok := M(path, Info); ok {
+				T(m, "hello")
+			}
+			if !handle.Remove() {
+				t.Errorf("%d: expected Remove to report true", i)
+			}
+			if handle.Remove() {
+				t.Errorf("%d: expected second Remove to report false", i)
+			}
+			if _, ok := M(path, Info); ok {
+				t.Errorf("%d: listener still matched after Remove", i)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 50; i++ {
+		if called[i] != 1 {
+			t.Errorf("%d: expected listener to be called once, got %d", i, called[i])
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package trace
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Tracer is bound to a single trace path and caches the listeners
+// that match each Priority level for that path.  The cache is
+// refreshed whenever a listener is registered or removed, so
+// Enabled costs little more than an atomic load.  A disabled Logf
+// still pays for its args, which the caller boxes into interface
+// values before Logf can check the cache, so guard calls on hot paths
+// with Enabled.
+//
+// A Tracer is safe for concurrent use by multiple goroutines.
+type Tracer struct {
+	registry *Registry
+	path     string
+	fields   Fields
+	cache    atomic.Pointer[tracerCache]
+}
+
+// tracerCache holds the matches for each Priority level computed
+// against the registry with the given generation.
+type tracerCache struct {
+	generation uint64
+	match      [None + 1][]listenerMatch
+}
+
+// New returns a new Tracer for path that uses the listeners installed
+// in DefaultRegistry.
+func New(path string) *Tracer {
+	return DefaultRegistry.Tracer(path)
+}
+
+// Path returns the trace path the Tracer was created with.
+func (t *Tracer) Path() string {
+	return t.path
+}
+
+// With returns a new Tracer for the same path that attaches fields,
+// in addition to any fields already attached to t, to each event.
+func (t *Tracer) With(fields ...Field) *Tracer {
+	joined := make(Fields, 0, len(t.fields)+len(fields))
+	joined = append(joined, t.fields...)
+	joined = append(joined, fields...)
+	return &Tracer{
+		registry: t.registry,
+		path:     t.path,
+		fields:   joined,
+	}
+}
+
+// Fields returns the fields attached to events logged by t.
+func (t *Tracer) Fields() Fields {
+	return t.fields
+}
+
+// Enabled returns true if any listener is interested in messages at
+// the specified priority level.
+func (t *Tracer) Enabled(priority Priority) bool {
+	return len(t.match(priority)) > 0
+}
+
+// Logf sends format and args to the listeners interested in
+// messages at the specified priority level.
+func (t *Tracer) Logf(priority Priority, format string, args ...interface{}) {
+	if m := t.match(priority); m != nil {
+		dispatch(m, time.Now(), t.fields, format, args)
+	}
+}
+
+// Tracef logs format and args at the Trace priority level.
+func (t *Tracer) Tracef(format string, args ...interface{}) {
+	t.Logf(Trace, format, args...)
+}
+
+// Debugf logs format and args at the Debug priority level.
+func (t *Tracer) Debugf(format string, args ...interface{}) {
+	t.Logf(Debug, format, args...)
+}
+
+// Infof logs format and args at the Info priority level.
+func (t *Tracer) Infof(format string, args ...interface{}) {
+	t.Logf(Info, format, args...)
+}
+
+// Warnf logs format and args at the Warn priority level.
+func (t *Tracer) Warnf(format string, args ...interface{}) {
+	t.Logf(Warn, format, args...)
+}
+
+// Errorf logs format and args at the Error priority level.
+func (t *Tracer) Errorf(format string, args ...interface{}) {
+	t.Logf(Error, format, args...)
+}
+
+// match returns the cached listener matches for priority, rebuilding
+// the cache if the registry has changed since it was last computed.
+func (t *Tracer) match(priority Priority) []listenerMatch {
+	if priority > None {
+		return nil
+	}
+
+	generation := t.registry.generation()
+	c := t.cache.Load()
+	if c == nil || c.generation != generation {
+		c = &tracerCache{generation: generation}
+		for p := Trace; p <= None; p++ {
+			c.match[p], _ = t.registry.M(t.path, p)
+		}
+		t.cache.Store(c)
+	}
+
+	return c.match[priority]
+}
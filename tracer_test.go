@@ -0,0 +1,98 @@
+package trace
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTracer(t *testing.T) {
+	tr := New("tracer/a")
+
+	if tr.Enabled(Error) {
+		t.Errorf("expected Error to be disabled before registering a listener")
+	}
+
+	var seen []string
+	handle := Register("tracer", Info, func(t time.Time, p string, n Priority, format string, args ...interface{}) {
+		seen = append(seen, fmt.Sprintf("%s %s %s", p, n, fmt.Sprintf(format, args...)))
+	})
+
+	if !tr.Enabled(Info) {
+		t.Errorf("expected Info to be enabled after registering a listener")
+	}
+	if tr.Enabled(Debug) {
+		t.Errorf("expected Debug to be disabled")
+	}
+
+	tr.Tracef("trace %d", 1)
+	tr.Debugf("debug %d", 2)
+	tr.Infof("info %d", 3)
+	tr.Warnf("warn %d", 4)
+	tr.Errorf("error %d", 5)
+
+	expected := []string{
+		"tracer/a Info info 3",
+		"tracer/a Warn warn 4",
+		"tracer/a Error error 5",
+	}
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %d messages, got %d: %v", len(expected), len(seen), seen)
+	}
+	for i, v := range expected {
+		if seen[i] != v {
+			t.Errorf("[%d] expected [%s] got [%s]", i, v, seen[i])
+		}
+	}
+
+	handle.Remove()
+
+	if tr.Enabled(Error) {
+		t.Errorf("expected Error to be disabled after removing the listener")
+	}
+	tr.Errorf("error %d", 6)
+	if len(seen) != len(expected) {
+		t.Errorf("listener called after Remove: %v", seen)
+	}
+}
+
+func BenchmarkTracerDisabled(b *testing.B) {
+	for _, path := range []string{"path1", "path2"} {
+		handle := Register(path, Info, discardListenerFn)
+		defer handle.Remove()
+	}
+	tr := New("/elsewhere")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Infof("%d\n", i)
+	}
+}
+
+func BenchmarkTracerDisabledGuarded(b *testing.B) {
+	for _, path := range []string{"path1", "path2"} {
+		handle := Register(path, Info, discardListenerFn)
+		defer handle.Remove()
+	}
+	tr := New("/elsewhere")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if tr.Enabled(Info) {
+			tr.Infof("%d\n", i)
+		}
+	}
+}
+
+func BenchmarkTracerEnabled(b *testing.B) {
+	for _, path := range []string{"path1", "path2"} {
+		handle := Register(path, Info, discardListenerFn)
+		defer handle.Remove()
+	}
+	tr := New("path1")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Infof("%d\n", i)
+	}
+}